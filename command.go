@@ -1,6 +1,12 @@
 package command
 
 import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+
 	"github.com/nedp/command/status"
 	"github.com/nedp/command/sequence"
 )
@@ -12,13 +18,19 @@ type Interface interface {
 	Stopper
 	State() State
 	Output() []string
+	Records() []sequence.Record
+	Snapshot() (sequence.Snapshot, bool)
+	Subscribe(int) (<-chan status.Event, func())
+	Err() error
 
 	// Name return's the command's assigned name.
 	Name() string
 }
 
 type Runner interface {
-	Run(chan<- string) bool
+	Run(chan<- string) error
+	RunContext(context.Context, chan<- string) error
+	RunRecords(context.Context, chan<- sequence.Record) error
 	IsRunning() bool
 }
 
@@ -33,11 +45,53 @@ type Stopper interface {
 	HasStopped() bool
 }
 
+// ErrStopped is recorded as the cause of failure when a command
+// is stopped with Stop.
+var ErrStopped = errors.New("the command was stopped")
+
+// ErrDryRunUnsupported is returned by a dry run of a command whose
+// RunAller isn't a `sequence.OptionRunAller`, and so can't list its
+// plan without running.
+var ErrDryRunUnsupported = errors.New("the command doesn't support dry runs")
+
 type Command struct {
 	name string
 	status status.Interface
 	runAller sequence.RunAller
-	logger logger
+	logger *logger
+
+	// Guards opts, which may be set while the command runs.
+	optsLock sync.Mutex
+	opts options
+}
+
+// The options of a command, set with its setters.
+// Each run uses the options set when it starts.
+type options struct {
+	// The time by which a run must finish, or zero for no deadline.
+	deadline time.Time
+
+	hooks sequence.Hooks
+	middleware []sequence.Middleware
+	workers *sequence.Workers
+
+	// Whether runs list their plan instead of running.
+	dryRun bool
+}
+
+// Returns
+// a copy of the command's current options.
+func (c *Command) currentOptions() options {
+	c.optsLock.Lock()
+	defer c.optsLock.Unlock()
+	return c.opts
+}
+
+// Changes the command's options with `set`.
+func (c *Command) setOption(set func(*options)) {
+	c.optsLock.Lock()
+	defer c.optsLock.Unlock()
+	set(&c.opts)
 }
 
 // New creates a new command object, initially allocating
@@ -48,8 +102,8 @@ type Command struct {
 // Returns
 // the new Command.
 func New(runAller sequence.RunAller, name string) *Command {
-	lg := newLogger(runAller.OutputChannel())
-	return &Command{name, status.New(), runAller, lg}
+	lg := newLogger()
+	return &Command{name: name, status: status.New(), runAller: runAller, logger: lg}
 }
 
 // NewForOutLength creates a new command object, initially allocating
@@ -61,26 +115,246 @@ func New(runAller sequence.RunAller, name string) *Command {
 // Returns
 // the new Command.
 func NewForOutLength(runAller sequence.RunAller, name string, outLen int) *Command {
-	lg := newLoggerWithCap(runAller.OutputChannel(), outLen)
-	return &Command{name, status.New(), runAller, lg}
+	lg := newLoggerWithCap(outLen)
+	return &Command{name: name, status: status.New(), runAller: runAller, logger: lg}
 }
 
 // Run calls RunAll on the command's RunAller, having the
-// command's logger record and forward output from the
-// sequence to outCh.
+// command's logger record output from the sequence and
+// forward it to outCh as strings.
+//
+// The logger stops recording output when the RunAller closes its
+// output channel, or once the RunAller has returned and no more of
+// its output is buffered. Output sent after that isn't recorded.
 //
-// The logger will stop recording output when the RunAller
-// is no longer running.
+// Run closes outCh once all of the output has been forwarded,
+// so outCh mustn't be closed by the caller, or reused for
+// another run.
 //
 // Returns
-// true if the status is fine;
-// false if there has been a failure.
-func (c *Command) Run(outCh chan<- string) bool {
-	go c.logger.listen(outCh)
+// nil if the status is fine;
+// the error(s) which caused the failure if there has been one.
+func (c *Command) Run(outCh chan<- string) error {
+	return c.RunContext(context.Background(), outCh)
+}
+
+// RunContext is like Run, but stops the command if `ctx` is
+// cancelled before the command finishes running.
+//
+// The cause of the cancellation is recorded as the cause
+// of the command's failure.
+//
+// Like Run, RunContext closes outCh when it returns.
+//
+// Returns
+// nil if the status is fine;
+// the error(s) which caused the failure if there has been one.
+func (c *Command) RunContext(ctx context.Context, outCh chan<- string) error {
+	records := make(chan sequence.Record)
+	adapted := make(chan struct{})
+	go func() {
+		defer close(adapted)
+		defer close(outCh)
+		for r := range records {
+			outCh <- r.String()
+		}
+	}()
+
+	err := c.RunRecords(ctx, records)
+	<-adapted
+	return err
+}
+
+// RunRecords is like RunContext, but forwards the output of the
+// sequence to outCh as structured records.
+//
+// Like Run, RunRecords closes outCh when it returns.
+//
+// Returns
+// nil if the status is fine;
+// the error(s) which caused the failure if there has been one.
+func (c *Command) RunRecords(ctx context.Context, outCh chan<- sequence.Record) error {
+	opts := c.currentOptions()
+	if _, ok := c.runAller.(sequence.OptionRunAller); opts.dryRun && !ok {
+		close(outCh)
+		return ErrDryRunUnsupported
+	}
+	c.status.Publish(status.Event{Kind: status.EventCommandStarted})
+	logged := make(chan struct{})
+	stopLogging := make(chan struct{})
+	go func(listen func()) {
+		defer close(logged)
+		defer close(outCh)
+		listen()
+	}(c.listener(outCh, stopLogging))
+
+	if !opts.deadline.IsZero() {
+		var cancel context.CancelFunc
+		timeout := &sequence.TimeoutError{Deadline: opts.deadline}
+		ctx, cancel = context.WithDeadlineCause(ctx, opts.deadline, timeout)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.status.FailWith(context.Cause(ctx))
+		case <-done:
+		}
+	}()
+
+	// Hooks may have side effects, so aren't run in a dry run.
+	if opts.dryRun {
+		opts.hooks = sequence.Hooks{}
+	}
+	hooks := opts.hooks
+	// If the before hook fails or panics, the RunAller runs nothing.
+	if hooks.Before != nil {
+		callHook(c.status, func() {
+			if err := hooks.Before(ctx); err != nil {
+				_ = c.status.FailWith(&sequence.HookError{Hook: "before", Err: err})
+			}
+		})
+	}
+
+	// Don't replace c.status, which may be in use concurrently.
+	stat := c.runAll(opts)
+	// Don't wait for RunAllers which don't close their output channel.
+	close(stopLogging)
+	<-logged
+	runAfterHooks(ctx, stat, hooks)
+	if hooks.Finally != nil {
+		callHook(stat, func() {
+			hooks.Finally(context.WithoutCancel(ctx))
+		})
+	}
+
+	var err error
+	if stat.HasFailed() {
+		err = stat.Err()
+	}
+	c.status.Publish(status.Event{Kind: status.EventCommandFinished, Err: err})
+	return err
+}
+
+// Returns
+// a function which has the logger record the output of the
+// current run of the RunAller, as records if it reports them,
+// and forward it to outCh until `stop` is closed.
+func (c *Command) listener(outCh chan<- sequence.Record, stop <-chan struct{}) func() {
+	if ra, ok := c.runAller.(sequence.RecordRunAller); ok {
+		in := ra.Records()
+		return func() { c.logger.listen(in, outCh, stop) }
+	}
+	in := c.runAller.OutputChannel()
+	return func() { c.logger.listenStrings(in, outCh, stop) }
+}
+
+// Runs the RunAller, with `opts` if it accepts them.
+func (c *Command) runAll(opts options) status.Interface {
+	ra, ok := c.runAller.(sequence.OptionRunAller)
+	if !ok || (len(opts.middleware) == 0 && opts.workers == nil && !opts.dryRun) {
+		return c.runAller.RunAll(c.status)
+	}
+	return ra.RunAllWith(c.status, sequence.RunOptions{
+		Middleware: opts.middleware,
+		Workers: opts.workers,
+		DryRun: opts.dryRun,
+	})
+}
+
+// Runs the After or OnError hook of `hooks`, depending on whether
+// the run recorded in `stat` failed.
+func runAfterHooks(ctx context.Context, stat status.Interface, hooks sequence.Hooks) {
+	if !stat.HasFailed() && hooks.After != nil {
+		callHook(stat, func() {
+			if err := hooks.After(ctx); err != nil {
+				_ = stat.FailWith(&sequence.HookError{Hook: "after", Err: err})
+			}
+		})
+	}
+	if stat.HasFailed() && hooks.OnError != nil {
+		callHook(stat, func() {
+			hooks.OnError(ctx, stat.Err())
+		})
+	}
+}
+
+// Calls the hook `fn`, recording a panic in it as a failure
+// of `stat` caused by a `*sequence.PanicError`.
+func callHook(stat status.Interface, fn func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			_ = stat.FailWith(&sequence.PanicError{Value: v, Stack: debug.Stack()})
+		}
+	}()
+	fn()
+}
+
+// SetHooks sets hooks to be run around each run of the command.
+//
+// The Before hook is run before the RunAller starts; if it fails,
+// the RunAller runs nothing. The After and OnError hooks are run
+// once the RunAller has finished, depending on whether the command
+// failed, and the Finally hook is run last, even if the command
+// failed. Failures of hooks are recorded as `*sequence.HookError`s,
+// and panics in them as `*sequence.PanicError`s.
+//
+// Like the other setters, SetHooks may be called while the command
+// runs; the change takes effect from the next run.
+func (c *Command) SetHooks(h sequence.Hooks) {
+	c.setOption(func(o *options) {
+		o.hooks = h
+	})
+}
+
+// Use wraps every unit run by the command with `mws`, outside of
+// any middleware registered with the sequence's builders.
+//
+// The first middleware is outermost. Middleware is ignored if the
+// command's RunAller isn't a `sequence.OptionRunAller`.
+func (c *Command) Use(mws ...sequence.Middleware) {
+	c.setOption(func(o *options) {
+		// Don't share the array with the copies of running commands.
+		o.middleware = append(o.middleware[:len(o.middleware):len(o.middleware)], mws...)
+	})
+}
 
-	c.status = c.runAller.RunAll(c.status)
-	c.logger.stop()
-	return !c.status.HasFailed()
+// SetWorkers limits the number of units run by the command at once.
+//
+// `w` may be shared with other commands, to limit the units they
+// run in total. A nil `w` removes the limit. The limit is ignored if
+// the command's RunAller isn't a `sequence.OptionRunAller`.
+func (c *Command) SetWorkers(w *sequence.Workers) {
+	c.setOption(func(o *options) {
+		o.workers = w
+	})
+}
+
+// SetDryRun sets whether runs of the command are dry runs.
+//
+// A dry run lists the plan of the command in its output, with the
+// description of each unit in the order and with the concurrency of
+// a real run, without running the units or the command's hooks.
+// See `sequence.RunOptions`. Dry runs return ErrDryRunUnsupported
+// if the command's RunAller isn't a `sequence.OptionRunAller`.
+func (c *Command) SetDryRun(dry bool) {
+	c.setOption(func(o *options) {
+		o.dryRun = dry
+	})
+}
+
+// SetDeadline sets a deadline for runs of the command.
+//
+// If a run hasn't finished by `t`, the command is stopped with a
+// `*sequence.TimeoutError` recorded as the cause of the failure.
+// A zero `t` removes the deadline.
+func (c *Command) SetDeadline(t time.Time) {
+	c.setOption(func(o *options) {
+		o.deadline = t
+	})
 }
 
 // A wrapper for status.Interface.Pause
@@ -98,9 +372,33 @@ func (c *Command) Cont() (bool, error) {
 	return c.status.Cont()
 }
 
-// A wrapper for status.Interface.Fail
+// A wrapper for status.Interface.FailWith, recording ErrStopped
+// as the cause of the failure.
+//
+// Subscribers are sent an `EventStopped` if the command
+// hadn't already failed.
 func (c *Command) Stop() error {
-	return c.status.Fail()
+	err := c.status.FailWith(ErrStopped)
+	if err == nil {
+		c.status.Publish(status.Event{Kind: status.EventStopped, Err: ErrStopped})
+	}
+	return err
+}
+
+// A wrapper for status.Interface.Subscribe
+//
+// Subscribers are sent the command's lifecycle events: when it
+// starts and finishes, when each phase is entered and exited, when
+// each unit starts, finishes or fails, and when the command is
+// paused, continued, fails or is stopped.
+// Events are dropped for subscribers whose buffer of `size`
+// events is full, so that they can't stall the run.
+//
+// Returns
+// the channel of events;
+// a function which unsubscribes and closes the channel.
+func (c *Command) Subscribe(size int) (<-chan status.Event, func()) {
+	return c.status.Subscribe(size)
 }
 
 // A wrapper for status.Interface.HasFailed
@@ -108,13 +406,51 @@ func (c *Command) HasStopped() bool {
 	return c.status.HasFailed()
 }
 
-// TODO document
+// A wrapper for status.Interface.Err
+//
+// Returns
+// nil if the command hasn't recorded any errors;
+// the error(s) which caused the command to fail, and any errors
+// which were tolerated, otherwise.
+func (c *Command) Err() error {
+	return c.status.Err()
+}
+
+// Output adapts the command's recorded output to strings.
+// See `sequence.Record.String`.
+//
+// Returns
+// a copy of the output recorded so far.
 func (c *Command) Output() []string {
-	var output []string
-	copy(output, c.logger.log)
+	records := c.logger.records()
+	output := make([]string, len(records))
+	for i, r := range records {
+		output[i] = r.String()
+	}
 	return output
 }
 
+// Records returns a copy of the structured output
+// recorded so far.
+func (c *Command) Records() []sequence.Record {
+	return c.logger.records()
+}
+
+// Snapshot describes the progress of the command's current run,
+// or of its last run if it isn't running.
+// Safe to call concurrently with Run.
+//
+// Returns
+// the snapshot of the command's RunAller;
+// whether the RunAller is a `sequence.Snapshotter`.
+func (c *Command) Snapshot() (sequence.Snapshot, bool) {
+	s, ok := c.runAller.(sequence.Snapshotter)
+	if !ok {
+		return sequence.Snapshot{}, false
+	}
+	return s.Snapshot(), true
+}
+
 // A wrapper for sequence.IsRunnig
 func (c *Command) IsRunning() bool {
 	return c.runAller.IsRunning()
@@ -125,6 +461,8 @@ type State struct {
 	IsRunning bool
 	HasStopped bool
 	Output []string
+	Records []sequence.Record
+	Err error
 }
 
 // State returns a threadsafe view of all externally visible
@@ -137,6 +475,8 @@ func (c *Command) State() State {
 		c.IsRunning(),
 		c.HasStopped(),
 		c.Output(),
+		c.Records(),
+		c.Err(),
 	}
 }
 
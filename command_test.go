@@ -1,30 +1,43 @@
 package command
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
-	"bitbucket.org/nedp/command/status"
+	"github.com/nedp/command/sequence"
+	"github.com/nedp/command/status"
 )
 
 type runAllerMock struct {
 	mock.Mock
 
 	duration time.Duration
+	output chan sequence.Record
 }
 
 func (ra *runAllerMock) RunAll(stat status.Interface) status.Interface {
 	args := ra.Called(stat)
 	fuse := time.After(ra.duration)
 	<-fuse
+	close(ra.output)
 	return args.Get(0).(status.Interface)
 }
 
+func (ra *runAllerMock) Records() <-chan sequence.Record {
+	ra.output = ra.Called().Get(0).(chan sequence.Record)
+	return ra.output
+}
+
+// Unused, since the mock reports records.
 func (ra *runAllerMock) OutputChannel() <-chan string {
-	return ra.Called().Get(0).(chan string)
+	return nil
 }
 
 func (ra *runAllerMock) IsRunning() bool {
@@ -32,6 +45,25 @@ func (ra *runAllerMock) IsRunning() bool {
 	return args.Bool(0)
 }
 
+// A RunAller which reports strings, and never closes its
+// output channel.
+type openRunAller struct {
+	output chan string
+}
+
+func (ra openRunAller) RunAll(stat status.Interface) status.Interface {
+	ra.output <- "output"
+	return stat
+}
+
+func (ra openRunAller) OutputChannel() <-chan string {
+	return ra.output
+}
+
+func (ra openRunAller) IsRunning() bool {
+	return false
+}
+
 // Test `New` in sequence with `Run`.
 // CC = ((1 + 1)total + 1) - 2 nodes
 //    = 1
@@ -50,9 +82,9 @@ func testRun(t *testing.T, expectSuccess bool, duration time.Duration) {
 	// Set up the sequence mock according to parameters.
 	runAller := new(runAllerMock)
 	runAller.duration = duration
-	output := make(chan string, 0)
-	runAller.On("OutputChannel").Return(output).Once()
-	c := New(runAller)
+	output := make(chan sequence.Record, 0)
+	runAller.On("Records").Return(output).Once()
+	c := New(runAller, "test")
 	runAller.On("RunAll", c.status).Return(c.status).Once()
 	runAller.duration = duration
 
@@ -62,7 +94,7 @@ func testRun(t *testing.T, expectSuccess bool, duration time.Duration) {
 	start := time.Now()
 	ch := make(chan bool)
 	go func() {
-		ch <- c.Run(make(chan string))
+		ch <- c.Run(make(chan string)) == nil
 	}()
 	fuse := time.After(duration * time.Duration(11) / time.Duration(10))
 
@@ -117,11 +149,11 @@ func TestNewLongSuccess(t *testing.T) {
 func TestStop(t *testing.T) {
 	runAller := new(runAllerMock)
 	runAller.duration = longDuration
-	output := make(chan string, 0)
-	runAller.On("OutputChannel").Return(output).Once()
+	output := make(chan sequence.Record, 0)
+	runAller.On("Records").Return(output).Once()
 
 	// There will be no output
-	c := New(runAller)
+	c := New(runAller, "test")
 	runAller.On("RunAll", c.status).Return(c.status).Once()
 
 	// The command should be externally stopped.
@@ -132,13 +164,281 @@ func TestStop(t *testing.T) {
 	}()
 	start := time.Now()
 
-	wasSuccessful := c.Run(cmdOut)
+	err := c.Run(cmdOut)
 
 	// The command finishes as soon as it's,
 	// so it shouldn't finish early.
 	assert.InEpsilon(t, int(longDuration), int(time.Since(start)), 0.3,
 		"Stop delay was wrong")
-	assert.False(t, wasSuccessful, "c.Run didn't return false")
+	assert.Equal(t, ErrStopped, err, "c.Run didn't return ErrStopped")
+	assert.Equal(t, ErrStopped, c.Err(), "c.Err didn't return ErrStopped")
 
 	runAller.AssertExpectations(t)
 }
+
+func TestRunContextCancelled(t *testing.T) {
+	t.Parallel()
+	seq := sequence.FirstJustCtx(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}).End()
+	c := New(seq, "test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shortDuration)
+	defer cancel()
+	start := time.Now()
+	err := c.RunContext(ctx, make(chan string))
+
+	assert.InEpsilon(t, int(shortDuration), int(time.Since(start)), 0.3,
+		"Cancellation delay was wrong")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSetDeadline(t *testing.T) {
+	t.Parallel()
+	seq := sequence.FirstJustCtx(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}).End()
+	c := New(seq, "test")
+	deadline := time.Now().Add(shortDuration)
+	c.SetDeadline(deadline)
+
+	err := c.Run(make(chan string))
+
+	assert.Equal(t, &sequence.TimeoutError{Deadline: deadline}, err)
+}
+
+func TestOutput(t *testing.T) {
+	t.Parallel()
+	seq := sequence.FirstJustUnit(func(ctx context.Context, out *sequence.Emitter) error {
+		out.Emit("A")
+		out.Log(sequence.SeverityError, "B")
+		return nil
+	}).End()
+	c := New(seq, "test")
+
+	outCh := make(chan string, 2)
+	err := c.Run(outCh)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1: A", "1: B"}, []string{<-outCh, <-outCh})
+	_, ok := <-outCh
+	assert.False(t, ok, "The output channel wasn't closed")
+	assert.Equal(t, []string{"1: A", "1: B"}, c.Output())
+	records := c.State().Records
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, sequence.SeverityError, records[1].Severity)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	c := New(sequence.FirstJust(func() error { return nil }).End(), "test")
+
+	assert.Nil(t, c.Run(make(chan string)))
+
+	s, ok := c.Snapshot()
+	assert.True(t, ok)
+	assert.Equal(t, sequence.Succeeded, s.State)
+
+	_, ok = New(new(runAllerMock), "test").Snapshot()
+	assert.False(t, ok)
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+	failure := errors.New("A failure")
+	c := New(sequence.FirstJust(func() error {
+		return nil
+	}).ThenJust(func() error {
+		return failure
+	}).End(), "test")
+	events, unsubscribe := c.Subscribe(16)
+
+	err := c.Run(make(chan string))
+	unsubscribe()
+
+	var got []string
+	for e := range events {
+		got = append(got, fmt.Sprintf("%v %s", e.Kind, e.Path))
+	}
+	assert.Equal(t, []string{
+		"command started ",
+		"phase entered 1",
+		"unit started 1",
+		"unit finished 1",
+		"phase exited 1",
+		"phase entered 2",
+		"unit started 2",
+		"unit failed 2",
+		"failed ",
+		"phase exited 2",
+		"command finished ",
+	}, got)
+	assert.ErrorIs(t, err, failure)
+}
+
+func TestRunOpenOutputChannel(t *testing.T) {
+	t.Parallel()
+	c := New(openRunAller{make(chan string, 1)}, "test")
+	outCh := make(chan string, 1)
+
+	done := make(chan error)
+	go func() {
+		done <- c.Run(outCh)
+	}()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Run waited for the output channel to be closed")
+	}
+	assert.Equal(t, "output", <-outCh)
+}
+
+func TestSetHooks(t *testing.T) {
+	t.Parallel()
+	failure := errors.New("A failure")
+	ran := false
+	c := New(sequence.FirstJust(func() error {
+		ran = true
+		return nil
+	}).End(), "test")
+	var calls []string
+	c.SetHooks(sequence.Hooks{
+		Before: func(context.Context) error {
+			calls = append(calls, "before")
+			return failure
+		},
+		After: func(context.Context) error {
+			calls = append(calls, "after")
+			return nil
+		},
+		OnError: func(_ context.Context, err error) {
+			calls = append(calls, "on error: "+err.Error())
+		},
+		Finally: func(context.Context) {
+			calls = append(calls, "finally")
+		},
+	})
+
+	err := c.Run(make(chan string))
+
+	assert.Equal(t, &sequence.HookError{Hook: "before", Err: failure}, err)
+	assert.False(t, ran, "The sequence ran after the before hook failed")
+	assert.Equal(t, []string{"before", "on error: before hook failed: A failure", "finally"}, calls)
+}
+
+func TestHookPanics(t *testing.T) {
+	t.Parallel()
+	c := New(sequence.FirstJust(func() error { return nil }).End(), "test")
+	var calls []string
+	c.SetHooks(sequence.Hooks{
+		After: func(context.Context) error {
+			panic("after")
+		},
+		OnError: func(_ context.Context, err error) {
+			calls = append(calls, "on error: "+err.Error())
+		},
+		Finally: func(context.Context) {
+			calls = append(calls, "finally")
+		},
+	})
+
+	err := c.Run(make(chan string))
+
+	var panicErr *sequence.PanicError
+	if assert.ErrorAs(t, err, &panicErr) {
+		assert.Equal(t, "after", panicErr.Value)
+	}
+	assert.Equal(t, []string{"on error: panic: after", "finally"}, calls)
+}
+
+func TestUse(t *testing.T) {
+	t.Parallel()
+	c := New(sequence.FirstJust(func() error { return nil }).End(), "test")
+	var paths []string
+	c.Use(func(next sequence.Unit) sequence.Unit {
+		return func(ctx context.Context, out *sequence.Emitter) error {
+			paths = append(paths, out.Path())
+			return next(ctx, out)
+		}
+	})
+
+	assert.Nil(t, c.Run(make(chan string)))
+	assert.Equal(t, []string{"1"}, paths)
+}
+
+func TestSetWorkers(t *testing.T) {
+	t.Parallel()
+	var running, max int32
+	work := func() error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if n > atomic.LoadInt32(&max) {
+			atomic.StoreInt32(&max, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+	c := New(sequence.PhaseOf(work).AndJust(work).AndJust(work).End(), "test")
+	c.SetWorkers(sequence.NewWorkers(1))
+
+	assert.Nil(t, c.Run(make(chan string)))
+	assert.Equal(t, int32(1), max)
+}
+
+func TestRunDAG(t *testing.T) {
+	t.Parallel()
+	var order []string
+	step := func(name string) func() error {
+		return func() error {
+			order = append(order, name)
+			return nil
+		}
+	}
+	seq, err := sequence.NewDAG().
+		AddJust("b", step("b"), "a").
+		AddJust("a", step("a")).
+		Build()
+	assert.Nil(t, err)
+	c := New(seq, "test")
+
+	assert.Nil(t, c.Run(make(chan string)))
+	assert.Equal(t, []string{"a", "b"}, order)
+}
+
+func TestSetDryRun(t *testing.T) {
+	t.Parallel()
+	called := false
+	hookCalled := false
+	c := New(sequence.SequenceOf(sequence.PhaseOf(func() error {
+		called = true
+		return nil
+	}).Describe(func(context.Context) string {
+		return "delete everything"
+	})).End(), "test")
+	c.SetHooks(sequence.Hooks{Before: func(context.Context) error {
+		hookCalled = true
+		return nil
+	}})
+	c.SetDryRun(true)
+
+	assert.Nil(t, c.Run(make(chan string, 1)))
+	assert.False(t, called)
+	assert.False(t, hookCalled)
+	assert.Equal(t, []string{"1: delete everything"}, c.Output())
+}
+
+func TestSetDryRunUnsupported(t *testing.T) {
+	t.Parallel()
+	c := New(new(runAllerMock), "test")
+	c.SetDryRun(true)
+	outCh := make(chan string)
+
+	assert.Equal(t, ErrDryRunUnsupported, c.Run(outCh))
+	_, open := <-outCh
+	assert.False(t, open)
+}
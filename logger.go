@@ -1,50 +1,87 @@
 package command
 
+import (
+	"sync"
+	"time"
+
+	"github.com/nedp/command/sequence"
+)
+
 type logger struct {
-	in <-chan string
-	stopCh chan struct{}
+	sync.Mutex
 
-	log []string
+	log []sequence.Record
 }
 
 const defaultCapacity = 8
 
-// Make a new logger with the default capacity, but specified channels.
-func newLogger(in <-chan string) logger {
-	return newLoggerWithCap(in, len(in))
+// Make a new logger with the default capacity.
+func newLogger() *logger {
+	return newLoggerWithCap(defaultCapacity)
 }
 
-// Make a new logger with specified capacity, and channels.
-func newLoggerWithCap(in <-chan string, capacity int) logger {
-	return logger{in, make(chan struct{}, 1), make([]string, 0, capacity)}
+// Make a new logger with specified capacity.
+func newLoggerWithCap(capacity int) *logger {
+	return &logger{log: make([]sequence.Record, 0, capacity)}
 }
 
 // Record input and forward it to output, until input is closed,
-// or the logger is stopped.
-func (lg *logger) listen(out chan<- string) {
-	defer close(out)
+// or the logger is stopped and no more input is buffered.
+//
+// Doesn't close output, which belongs to the caller.
+func (lg *logger) listen(in <-chan sequence.Record, out chan<- sequence.Record, stop <-chan struct{}) {
+	receive(in, stop, func(r sequence.Record) {
+		lg.forward(r, out)
+	})
+}
+
+// Like listen, but records each string of input as the text of
+// a record, for RunAllers which don't report records.
+func (lg *logger) listenStrings(in <-chan string, out chan<- sequence.Record, stop <-chan struct{}) {
+	receive(in, stop, func(s string) {
+		lg.forward(sequence.Record{Time: time.Now(), Text: s}, out)
+	})
+}
+
+// Passes each value received from `in` to `fn`, until `in` is closed,
+// or `stop` is closed and no more values are buffered in `in`.
+func receive[T any](in <-chan T, stop <-chan struct{}, fn func(T)) {
 	for {
 		select {
-		case s, ok := <-lg.in:
+		case v, ok := <-in:
 			if !ok {
 				return
 			}
-			lg.log = append(lg.log, s)
-			out <- s
-		case <-lg.stopCh:
-			lg.stopCh <- struct{}{}
-			return
+			fn(v)
+		case <-stop:
+			// Take what was sent before the logger was stopped.
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						return
+					}
+					fn(v)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
 
-// Stops the logger.
-// Doesn't block if the logger's already stopped.
-func (lg logger) stop() {
-	select {
-	case <-lg.stopCh:
-		lg.stopCh <- struct{}{}
-	default:
-		lg.stopCh <- struct{}{}
-	}
+// Record `r`, and forward it to output.
+func (lg *logger) forward(r sequence.Record, out chan<- sequence.Record) {
+	lg.Lock()
+	lg.log = append(lg.log, r)
+	lg.Unlock()
+	out <- r
+}
+
+// Returns
+// a copy of the records logged so far.
+func (lg *logger) records() []sequence.Record {
+	lg.Lock()
+	defer lg.Unlock()
+	return append([]sequence.Record(nil), lg.log...)
 }
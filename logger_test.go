@@ -5,30 +5,37 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/sequence"
 )
 
 func TestLogger(t *testing.T) {
 	t.Parallel()
-	testStrings := []string{
-		"test string 1",
-		"test string 2",
+	testRecords := []sequence.Record{
+		{Text: "test string 1"},
+		{Path: "1", Stream: sequence.StreamStderr, Text: "test string 2"},
 	}
 
-	in := make(chan string)
+	in := make(chan sequence.Record)
 	done := make(chan struct{})
-	go func(out chan<- string, done chan<- struct{}) {
-		for _, s := range testStrings {
-			out <- s
+	go func(out chan<- sequence.Record, done chan<- struct{}) {
+		for _, r := range testRecords {
+			out <- r
 		}
+		close(out)
 		done <- struct{}{}
 	}(in, done)
 
-	out := make(chan string)
-	lg := newLogger(in)
-	go lg.listen(out)
+	out := make(chan sequence.Record)
+	listened := make(chan struct{})
+	lg := newLogger()
+	go func() {
+		lg.listen(in, out, nil)
+		close(listened)
+	}()
 
-	for i := range testStrings {
-		assert.Equal(t, testStrings[i], <-out, "Test string %d didn't match out", i)
+	for i := range testRecords {
+		assert.Equal(t, testRecords[i], <-out, "Test record %d didn't match out", i)
 	}
 	const timeout = time.Duration(1) * time.Second
 	fuse := time.After(timeout)
@@ -39,8 +46,30 @@ func TestLogger(t *testing.T) {
 		// Okay
 	}
 
-	println(len(lg.log))
-	for i, s := range testStrings {
-		assert.Equal(t, s, lg.log[i], "Test string %d didn't match the log", i)
+	select {
+	case <-time.After(timeout):
+		t.Error("The logger didn't stop when in was closed")
+	case <-listened:
+		// Okay
 	}
+	assert.Equal(t, testRecords, lg.records(), "The records didn't match the log")
+}
+
+func TestLoggerStopped(t *testing.T) {
+	t.Parallel()
+	record := sequence.Record{Text: "test string"}
+
+	// Never closed.
+	in := make(chan sequence.Record, 1)
+	in <- record
+	out := make(chan sequence.Record, 1)
+	stop := make(chan struct{})
+	close(stop)
+
+	lg := newLogger()
+	lg.listen(in, out, stop)
+
+	// Buffered input is still forwarded.
+	assert.Equal(t, record, <-out)
+	assert.Equal(t, []sequence.Record{record}, lg.records())
 }
@@ -0,0 +1,427 @@
+/*
+Package pipeline loads sequences from declarative YAML or JSON
+documents, so that command trees may be defined without writing Go.
+
+A document describes a sequence:
+
+	name: deploy
+	timeout: 10m
+	phases:
+	  - name: build
+	    unit: compile
+	    params: {target: linux, jobs: 4}
+	    retry: {attempts: 3, backoff: 1s}
+	  - name: rollout
+	    max_parallel: 2
+	    sequences:
+	      - phases: [{unit: deploy-eu}]
+	      - phases: [{unit: deploy-us}]
+
+A sequence has a list of phases, and optionally a name, a timeout
+and a failure policy. A phase has a unit, sub-sequences, or both;
+and optionally a name, a timeout, a retry policy, a failure policy,
+a quorum and a limit of sub-sequences running at once. Units are
+referred to by the names they're registered under in a Registry,
+and are made from the phase's params if they're registered with
+a Factory.
+
+Durations are written like "1m30s". Failure policies are written as
+"fail-fast", "continue-on-error" or "isolate". A retry policy has a
+number of attempts, and optionally a backoff, which doubles with each
+retry up to max_backoff if that is set.
+*/
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nedp/command/sequence"
+)
+
+// ErrNoRegistry is returned when a document is parsed without
+// a Registry to look up its units in.
+var ErrNoRegistry = errors.New("no registry was given to look up units in")
+
+// Error describes a problem with a pipeline document.
+type Error struct {
+	// The path in the document of the offending value,
+	// such as "$.phases[1].timeout".
+	Path string
+
+	// The position of the offending value in the document,
+	// or 0 if it isn't known.
+	Line int
+	Column int
+
+	Msg string
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return e.Path + ": " + e.Msg
+	}
+	return fmt.Sprintf("%s (line %d, column %d): %s", e.Path, e.Line, e.Column, e.Msg)
+}
+
+// Reads a pipeline document from `r`, and builds its sequence.
+// See `Parse`.
+//
+// Returns
+// the sequence;
+// an error if the document couldn't be read or is invalid.
+func Load(r io.Reader, reg *Registry) (sequence.Sequence, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return sequence.Sequence{}, err
+	}
+	return Parse(data, reg)
+}
+
+// Parses a YAML or JSON pipeline document, and builds its sequence
+// with the units registered in `reg`.
+//
+// Returns
+// the sequence, ready to be run;
+// an error joining an `*Error` for each problem with the document,
+// if it is invalid;
+// ErrNoRegistry if `reg` is nil.
+func Parse(data []byte, reg *Registry) (sequence.Sequence, error) {
+	if reg == nil {
+		return sequence.Sequence{}, ErrNoRegistry
+	}
+	var doc yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&doc); err != nil {
+		if err == io.EOF {
+			err = errors.New("empty document")
+		}
+		return sequence.Sequence{}, &Error{Path: "$", Msg: err.Error()}
+	}
+	// Don't ignore the documents after the first.
+	var next yaml.Node
+	switch err := dec.Decode(&next); {
+	case err == nil:
+		return sequence.Sequence{}, &Error{"$", next.Line, next.Column, "expected a single document"}
+	case err != io.EOF:
+		return sequence.Sequence{}, &Error{Path: "$", Msg: err.Error()}
+	}
+
+	d := decoder{reg: reg}
+	sb, ok := d.sequence("$", doc.Content[0])
+	if len(d.errs) > 0 || !ok {
+		return sequence.Sequence{}, errors.Join(d.errs...)
+	}
+	return sb.End(), nil
+}
+
+// Decodes the nodes of a document, collecting their errors.
+type decoder struct {
+	reg *Registry
+	errs []error
+}
+
+// Records an error with the value `n` at `path`.
+func (d *decoder) fail(path string, n *yaml.Node, format string, args ...interface{}) {
+	d.errs = append(d.errs, &Error{path, n.Line, n.Column, fmt.Sprintf(format, args...)})
+}
+
+// Returns
+// the values of the fields of the mapping `n`, by key, recording
+// errors for keys which aren't in `known` and for repeated keys;
+// whether `n` is a mapping.
+func (d *decoder) fields(path string, n *yaml.Node, known ...string) (map[string]*yaml.Node, bool) {
+	if n.Kind != yaml.MappingNode {
+		d.fail(path, n, "expected a mapping")
+		return nil, false
+	}
+	fields := make(map[string]*yaml.Node, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if !contains(known, k.Value) {
+			d.fail(path+"."+k.Value, k, "unknown field")
+			continue
+		}
+		if _, ok := fields[k.Value]; ok {
+			d.fail(path+"."+k.Value, k, "repeated field")
+			continue
+		}
+		fields[k.Value] = v
+	}
+	return fields, true
+}
+
+// Returns
+// a builder for the sequence described by `n`;
+// whether it could be built, ignoring errors in its fields which
+// were recorded.
+func (d *decoder) sequence(path string, n *yaml.Node) (sequence.SequenceBuilder, bool) {
+	fields, ok := d.fields(path, n, "name", "timeout", "policy", "phases")
+	if !ok {
+		return sequence.SequenceBuilder{}, false
+	}
+	phases, ok := fields["phases"]
+	if !ok {
+		d.fail(path, n, "missing field \"phases\"")
+		return sequence.SequenceBuilder{}, false
+	}
+	if phases.Kind != yaml.SequenceNode || len(phases.Content) == 0 {
+		d.fail(path+".phases", phases, "expected a non-empty list")
+		return sequence.SequenceBuilder{}, false
+	}
+
+	var sb sequence.SequenceBuilder
+	built := true
+	for i, p := range phases.Content {
+		pb, ok := d.phase(fmt.Sprintf("%s.phases[%d]", path, i), p)
+		switch {
+		case !ok:
+			// Keep going, to find the errors of the other phases.
+			built = false
+		case i == 0:
+			sb = sequence.SequenceOf(pb)
+		default:
+			sb = sb.Then(pb)
+		}
+	}
+	if !built {
+		return sequence.SequenceBuilder{}, false
+	}
+
+	if v, ok := fields["name"]; ok {
+		sb = sb.Name(d.str(path+".name", v))
+	}
+	if v, ok := fields["timeout"]; ok {
+		sb = sb.Timeout(d.duration(path+".timeout", v))
+	}
+	if v, ok := fields["policy"]; ok {
+		sb = sb.Policy(d.policy(path+".policy", v))
+	}
+	return sb, true
+}
+
+// Returns
+// a builder for the phase described by `n`;
+// whether it could be built, ignoring errors in its fields which
+// were recorded.
+func (d *decoder) phase(path string, n *yaml.Node) (sequence.PhaseBuilder, bool) {
+	fields, ok := d.fields(path, n, "name", "unit", "params", "sequences",
+		"timeout", "retry", "policy", "quorum", "max_parallel")
+	if !ok {
+		return sequence.PhaseBuilder{}, false
+	}
+
+	unit, params, seqs := fields["unit"], fields["params"], fields["sequences"]
+	if unit == nil && seqs == nil {
+		d.fail(path, n, "expected a unit or sequences")
+		return sequence.PhaseBuilder{}, false
+	}
+	var pb sequence.PhaseBuilder
+	if unit == nil {
+		if params != nil {
+			d.fail(path+".params", params, "parameters without a unit")
+		}
+		pb = sequence.PhaseOfSequences()
+	} else {
+		pb = d.unit(path, unit, params)
+	}
+
+	if seqs != nil {
+		if seqs.Kind != yaml.SequenceNode {
+			d.fail(path+".sequences", seqs, "expected a list")
+		} else {
+			for i, s := range seqs.Content {
+				if sb, ok := d.sequence(fmt.Sprintf("%s.sequences[%d]", path, i), s); ok {
+					pb = pb.And(sb)
+				}
+			}
+		}
+	}
+
+	if v, ok := fields["name"]; ok {
+		pb = pb.Name(d.str(path+".name", v))
+	}
+	if v, ok := fields["timeout"]; ok {
+		pb = pb.Timeout(d.duration(path+".timeout", v))
+	}
+	if v, ok := fields["retry"]; ok {
+		pb = pb.Retry(d.retry(path+".retry", v))
+	}
+	if v, ok := fields["policy"]; ok {
+		pb = pb.Policy(d.policy(path+".policy", v))
+	}
+	if v, ok := fields["quorum"]; ok {
+		quorum := d.count(path+".quorum", v)
+		var total int
+		if seqs != nil && seqs.Kind == yaml.SequenceNode {
+			total = len(seqs.Content)
+		}
+		if quorum > total {
+			d.fail(path+".quorum", v, "unreachable with %d sequences", total)
+		}
+		pb = pb.Quorum(quorum)
+	}
+	if v, ok := fields["max_parallel"]; ok {
+		pb = pb.MaxParallel(d.count(path+".max_parallel", v))
+	}
+	return pb, true
+}
+
+// Returns
+// a builder for a phase whose main function is made by the unit
+// factory named by `unit`, from the parameters `params`, which may
+// be nil.
+func (d *decoder) unit(path string, unit, params *yaml.Node) sequence.PhaseBuilder {
+	name := d.str(path+".unit", unit)
+	values := make(map[string]interface{})
+	if params != nil {
+		if params.Kind != yaml.MappingNode {
+			d.fail(path+".params", params, "expected a mapping")
+		} else if err := params.Decode(&values); err != nil {
+			d.fail(path+".params", params, "%v", err)
+		}
+	}
+	if unit.Tag != "!!str" {
+		return sequence.PhaseBuilder{}
+	}
+
+	pb, err := d.reg.Phase(name, values)
+	if err == nil {
+		return pb
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		var perr *ParamError
+		switch {
+		case !errors.As(err, &perr):
+			d.fail(path+".unit", unit, "%v", err)
+		case perr.Param == "":
+			d.fail(path+".unit", unit, "unit %q %s", name, perr.Msg)
+		default:
+			// Point at the parameter if it was given.
+			at := unit
+			if params != nil {
+				at = params
+				if k := key(params, perr.Param); k != nil {
+					at = k
+				}
+			}
+			d.fail(path+".params."+perr.Param, at, "parameter %s", perr.Msg)
+		}
+	}
+	return pb
+}
+
+// Returns
+// the key `name` in the mapping `n`, or nil if it isn't there.
+func key(n *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == name {
+			return n.Content[i]
+		}
+	}
+	return nil
+}
+
+// Returns
+// the retry policy described by `n`.
+func (d *decoder) retry(path string, n *yaml.Node) sequence.RetryPolicy {
+	fields, ok := d.fields(path, n, "attempts", "backoff", "max_backoff")
+	if !ok {
+		return sequence.RetryPolicy{}
+	}
+	var rp sequence.RetryPolicy
+	if v, ok := fields["attempts"]; ok {
+		rp.MaxAttempts = d.count(path+".attempts", v)
+	} else {
+		d.fail(path, n, "missing field \"attempts\"")
+	}
+
+	var backoff, max time.Duration
+	if v, ok := fields["backoff"]; ok {
+		backoff = d.duration(path+".backoff", v)
+	}
+	if v, ok := fields["max_backoff"]; ok {
+		max = d.duration(path+".max_backoff", v)
+	}
+	switch {
+	case max > 0:
+		rp.Backoff = sequence.ExponentialBackoff(backoff, max)
+	case backoff > 0:
+		rp.Backoff = sequence.ConstantBackoff(backoff)
+	}
+	return rp
+}
+
+// Returns
+// the string `n`, or "" if it isn't one.
+func (d *decoder) str(path string, n *yaml.Node) string {
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!str" {
+		d.fail(path, n, "expected a string")
+		return ""
+	}
+	return n.Value
+}
+
+// Returns
+// the non-negative integer `n`, or 0 if it isn't one.
+func (d *decoder) count(path string, n *yaml.Node) int {
+	i, err := strconv.Atoi(n.Value)
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!int" || err != nil || i < 0 {
+		d.fail(path, n, "expected a non-negative integer")
+		return 0
+	}
+	return i
+}
+
+// Returns
+// the duration `n`, or 0 if it isn't one.
+func (d *decoder) duration(path string, n *yaml.Node) time.Duration {
+	s := d.str(path, n)
+	if s == "" {
+		return 0
+	}
+	t, err := time.ParseDuration(s)
+	if err != nil || t < 0 {
+		d.fail(path, n, "invalid duration %q", s)
+		return 0
+	}
+	return t
+}
+
+// Returns
+// the failure policy `n`, or FailFast if it isn't one.
+func (d *decoder) policy(path string, n *yaml.Node) sequence.FailurePolicy {
+	switch s := d.str(path, n); s {
+	case "fail-fast":
+		return sequence.FailFast
+	case "continue-on-error":
+		return sequence.ContinueOnError
+	case "isolate":
+		return sequence.Isolate
+	case "":
+		return sequence.FailFast
+	default:
+		d.fail(path, n, "unknown policy %q", s)
+		return sequence.FailFast
+	}
+}
+
+// Whether `ss` contains `s`.
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/sequence"
+	"github.com/nedp/command/status"
+)
+
+// Returns
+// a registry of units which append their names to `ran`,
+// and a unit named "fail" which fails.
+func registry(ran *[]string) *Registry {
+	reg := NewRegistry()
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		reg.Register(name, func(context.Context, *sequence.Emitter) error {
+			*ran = append(*ran, name)
+			return nil
+		})
+	}
+	reg.Register("fail", func(context.Context, *sequence.Emitter) error {
+		return errors.New("A failure")
+	})
+	return reg
+}
+
+// Runs `seq`, discarding its output.
+//
+// Returns
+// the status of the run.
+func run(seq sequence.Sequence) status.Interface {
+	go func(records <-chan sequence.Record) {
+		for range records {
+		}
+	}(seq.Records())
+	return seq.RunAll(status.New())
+}
+
+func TestParseYAML(t *testing.T) {
+	var ran []string
+	seq, err := Parse([]byte(`
+name: deploy
+timeout: 1m
+phases:
+  - name: first
+    unit: a
+    retry: {attempts: 3, backoff: 1ms, max_backoff: 10ms}
+  - name: second
+    unit: b
+    max_parallel: 1
+    policy: continue-on-error
+    sequences:
+      - name: inner
+        phases: [{unit: c}]
+`), registry(&ran))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stat := run(seq)
+
+	assert.False(t, stat.HasFailed())
+	assert.Len(t, ran, 3)
+	assert.Equal(t, "a", ran[0])
+	paths := []string{}
+	for _, c := range seq.Snapshot().Children {
+		paths = append(paths, c.Path)
+		for _, cc := range c.Children {
+			paths = append(paths, cc.Path)
+		}
+	}
+	assert.Equal(t, []string{"deploy/first", "deploy/second", "deploy/second/inner"}, paths)
+}
+
+func TestParseJSON(t *testing.T) {
+	var ran []string
+	seq, err := Parse([]byte(`{
+	"phases": [
+		{"unit": "a"},
+		{"unit": "fail", "retry": {"attempts": 2}},
+		{"unit": "b"}
+	]
+}`), registry(&ran))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stat := run(seq)
+
+	assert.True(t, stat.HasFailed())
+	assert.EqualError(t, stat.Err(), "2: after 2 attempts: A failure")
+	assert.Equal(t, []string{"a"}, ran)
+}
+
+func TestLoad(t *testing.T) {
+	var ran []string
+	seq, err := Load(strings.NewReader("phases: [{unit: a}]"), registry(&ran))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	run(seq)
+
+	assert.Equal(t, []string{"a"}, ran)
+}
+
+func TestParseErrors(t *testing.T) {
+	var ran []string
+	_, err := Parse([]byte(`
+phases:
+  - unit: missing
+    timeout: soon
+  - sequences:
+      - phases:
+          - unit: a
+            quorum: -1
+            colour: blue
+  - name: empty
+`), registry(&ran))
+
+	assert.EqualError(t, err, strings.Join([]string{
+		`$.phases[0].unit (line 3, column 11): unit "missing" is not registered`,
+		`$.phases[0].timeout (line 4, column 14): invalid duration "soon"`,
+		`$.phases[1].sequences[0].phases[0].colour (line 9, column 13): unknown field`,
+		`$.phases[1].sequences[0].phases[0].quorum (line 8, column 21): expected a non-negative integer`,
+		`$.phases[2] (line 10, column 5): expected a unit or sequences`,
+	}, "\n"))
+
+	var perr *Error
+	if assert.True(t, errors.As(err, &perr)) {
+		assert.Equal(t, "$.phases[0].unit", perr.Path)
+		assert.Equal(t, 3, perr.Line)
+	}
+}
+
+func TestParseInvalidDocuments(t *testing.T) {
+	for doc, want := range map[string]string{
+		``: `$: empty document`,
+		`[1, 2]`: `$ (line 1, column 1): expected a mapping`,
+		`name: x`: `$ (line 1, column 1): missing field "phases"`,
+		`phases: []`: `$.phases (line 1, column 9): expected a non-empty list`,
+		`phases: [{unit: a}, {unit: b, policy: never}]`: `$.phases[1].policy (line 1, column 39): unknown policy "never"`,
+		"phases: [{unit: a}]\n---\nphases: [{unit: b}]": `$ (line 2, column 1): expected a single document`,
+		`phases: [{unit: a, quorum: 1}]`: `$.phases[0].quorum (line 1, column 28): unreachable with 0 sequences`,
+	} {
+		var ran []string
+		_, err := Parse([]byte(doc), registry(&ran))
+		assert.EqualError(t, err, want, doc)
+	}
+}
+
+func TestParseNoRegistry(t *testing.T) {
+	_, err := Parse([]byte("phases: [{unit: a}]"), nil)
+
+	assert.Equal(t, ErrNoRegistry, err)
+}
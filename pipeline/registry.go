@@ -0,0 +1,305 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nedp/command/sequence"
+)
+
+// The type of a parameter of a unit factory.
+type ParamType int
+
+const (
+	String ParamType = iota
+	Int
+	Float
+	Bool
+
+	// Written like "1m30s", or given as a time.Duration.
+	Duration
+)
+
+func (t ParamType) String() string {
+	switch t {
+	case String:
+		return "string"
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Bool:
+		return "bool"
+	case Duration:
+		return "duration"
+	}
+	return "unknown"
+}
+
+// A Param describes a parameter of a unit factory.
+type Param struct {
+	Name string
+	Type ParamType
+
+	// Whether the parameter must be given.
+	Required bool
+
+	// The value of the parameter if it isn't given, or nil for
+	// the zero value of its type. Ignored if the parameter is
+	// required.
+	Default interface{}
+}
+
+// Params holds the validated parameters passed to a unit factory,
+// by name. Each holds a value of the Go type matching its ParamType:
+// string, int, float64, bool or time.Duration.
+type Params map[string]interface{}
+
+// Returns
+// the String parameter `name`.
+func (p Params) String(name string) string {
+	s, _ := p[name].(string)
+	return s
+}
+
+// Returns
+// the Int parameter `name`.
+func (p Params) Int(name string) int {
+	i, _ := p[name].(int)
+	return i
+}
+
+// Returns
+// the Float parameter `name`.
+func (p Params) Float(name string) float64 {
+	f, _ := p[name].(float64)
+	return f
+}
+
+// Returns
+// the Bool parameter `name`.
+func (p Params) Bool(name string) bool {
+	b, _ := p[name].(bool)
+	return b
+}
+
+// Returns
+// the Duration parameter `name`.
+func (p Params) Duration(name string) time.Duration {
+	d, _ := p[name].(time.Duration)
+	return d
+}
+
+// A Factory makes a unit from its validated parameters.
+type Factory func(params Params) (sequence.Unit, error)
+
+// ParamError describes a problem with the parameters given
+// for a unit.
+type ParamError struct {
+	Unit string
+
+	// The parameter with the problem, or "" if the unit
+	// isn't registered.
+	Param string
+
+	// The problem, such as "is required".
+	Msg string
+}
+
+func (e *ParamError) Error() string {
+	if e.Param == "" {
+		return fmt.Sprintf("unit %q %s", e.Unit, e.Msg)
+	}
+	return fmt.Sprintf("unit %q: parameter %q %s", e.Unit, e.Param, e.Msg)
+}
+
+// A Registry holds the unit implementations which pipeline documents
+// refer to by name, and the factories which make units from
+// parameters.
+//
+// A Registry is safe for concurrent use.
+type Registry struct {
+	sync.RWMutex
+	factories map[string]factory
+}
+
+// A registered factory, with its parameter schema.
+type factory struct {
+	schema []Param
+	make Factory
+}
+
+// Creates an empty registry.
+//
+// Returns
+// the registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]factory)}
+}
+
+// Registers `u` under `name`, as a unit without parameters,
+// replacing anything already registered under it.
+func (r *Registry) Register(name string, u sequence.Unit) {
+	r.RegisterFactory(name, nil, func(Params) (sequence.Unit, error) {
+		return u, nil
+	})
+}
+
+// Registers `f` under `name`, to make units from the parameters
+// described by `schema`, replacing anything already registered
+// under it.
+func (r *Registry) RegisterFactory(name string, schema []Param, f Factory) {
+	r.Lock()
+	defer r.Unlock()
+	r.factories[name] = factory{schema, f}
+}
+
+// Makes a unit with the factory registered under `name`.
+//
+// `params` is validated against the factory's schema first: each
+// must be known and of its parameter's type, and each required
+// parameter must be given. Integers are accepted as Floats, and
+// strings like "1m30s" as Durations.
+//
+// Returns
+// the unit;
+// an error joining a `*ParamError` for each problem with `params`,
+// or the error of the factory.
+func (r *Registry) Unit(name string, params map[string]interface{}) (sequence.Unit, error) {
+	r.RLock()
+	f, ok := r.factories[name]
+	r.RUnlock()
+	if !ok {
+		return nil, &ParamError{Unit: name, Msg: "is not registered"}
+	}
+
+	valid, err := f.validate(name, params)
+	if err != nil {
+		return nil, err
+	}
+	u, err := f.make(valid)
+	if err != nil {
+		return nil, fmt.Errorf("unit %q: %w", name, err)
+	}
+	return u, nil
+}
+
+// Starts building a phase with a unit made by the factory registered
+// under `name` as its main function. See `Unit`.
+//
+// Returns
+// a phase builder for the phase;
+// an error if the unit couldn't be made.
+func (r *Registry) Phase(name string, params map[string]interface{}) (sequence.PhaseBuilder, error) {
+	u, err := r.Unit(name, params)
+	if err != nil {
+		return sequence.PhaseBuilder{}, err
+	}
+	return sequence.PhaseOfUnit(u), nil
+}
+
+// Checks `params` against the factory's schema, and fills in
+// the defaults of missing optional parameters.
+//
+// Returns
+// the validated parameters;
+// an error joining a `*ParamError` for each problem.
+func (f factory) validate(unit string, params map[string]interface{}) (Params, error) {
+	var errs []error
+	valid := make(Params, len(f.schema))
+	known := make(map[string]bool, len(f.schema))
+	for _, p := range f.schema {
+		known[p.Name] = true
+		v, ok := params[p.Name]
+		switch {
+		case ok:
+		case p.Required:
+			errs = append(errs, &ParamError{unit, p.Name, "is required"})
+			continue
+		case p.Default != nil:
+			v = p.Default
+		default:
+			v = p.Type.zero()
+		}
+		if v, ok = p.Type.convert(v); !ok {
+			errs = append(errs, &ParamError{unit, p.Name, "must be of type " + p.Type.String()})
+			continue
+		}
+		valid[p.Name] = v
+	}
+
+	// Report unknown parameters in a consistent order.
+	var unknown []string
+	for name := range params {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, &ParamError{unit, name, "is unknown"})
+	}
+	return valid, errors.Join(errs...)
+}
+
+// Returns
+// the zero value of the type.
+func (t ParamType) zero() interface{} {
+	switch t {
+	case Int:
+		return 0
+	case Float:
+		return 0.0
+	case Bool:
+		return false
+	case Duration:
+		return time.Duration(0)
+	}
+	return ""
+}
+
+// Returns
+// `v` as a value of the type;
+// whether it could be converted.
+func (t ParamType) convert(v interface{}) (interface{}, bool) {
+	switch t {
+	case String:
+		s, ok := v.(string)
+		return s, ok
+	case Int:
+		switch n := v.(type) {
+		case int:
+			return n, true
+		case int64:
+			return int(n), true
+		case float64:
+			// Numbers decoded from JSON are floats.
+			return int(n), n == math.Trunc(n)
+		}
+	case Float:
+		switch n := v.(type) {
+		case float64:
+			return n, true
+		case int:
+			return float64(n), true
+		case int64:
+			return float64(n), true
+		}
+	case Bool:
+		b, ok := v.(bool)
+		return b, ok
+	case Duration:
+		switch d := v.(type) {
+		case time.Duration:
+			return d, true
+		case string:
+			parsed, err := time.ParseDuration(d)
+			return parsed, err == nil
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/sequence"
+)
+
+// Returns
+// a registry with a factory named "greet" whose units append
+// greetings to `out`.
+func greeter(out *[]string) *Registry {
+	reg := NewRegistry()
+	reg.RegisterFactory("greet", []Param{
+		{Name: "name", Type: String, Required: true},
+		{Name: "times", Type: Int, Default: 1},
+		{Name: "delay", Type: Duration},
+		{Name: "shout", Type: Bool},
+	}, func(p Params) (sequence.Unit, error) {
+		if p.Int("times") > 3 {
+			return nil, errors.New("too many greetings")
+		}
+		greeting := "hello " + p.String("name")
+		if p.Bool("shout") {
+			greeting += "!"
+		}
+		return func(context.Context, *sequence.Emitter) error {
+			time.Sleep(p.Duration("delay"))
+			for i := 0; i < p.Int("times"); i += 1 {
+				*out = append(*out, greeting)
+			}
+			return nil
+		}, nil
+	})
+	return reg
+}
+
+func TestRegistryPhase(t *testing.T) {
+	var out []string
+	pb, err := greeter(&out).Phase("greet", map[string]interface{}{
+		"name": "A", "times": 2, "delay": "1ms", "shout": true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stat := run(pb.End())
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, []string{"hello A!", "hello A!"}, out)
+}
+
+func TestRegistryDefaults(t *testing.T) {
+	var out []string
+	u, err := greeter(&out).Unit("greet", map[string]interface{}{"name": "B"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, u(context.Background(), nil))
+	assert.Equal(t, []string{"hello B"}, out)
+}
+
+func TestRegistryInvalidParams(t *testing.T) {
+	var out []string
+	_, err := greeter(&out).Unit("greet", map[string]interface{}{
+		"times": "twice", "colour": "blue", "volume": 11,
+	})
+
+	assert.EqualError(t, err, `unit "greet": parameter "name" is required
+unit "greet": parameter "times" must be of type int
+unit "greet": parameter "colour" is unknown
+unit "greet": parameter "volume" is unknown`)
+	var perr *ParamError
+	if assert.True(t, errors.As(err, &perr)) {
+		assert.Equal(t, &ParamError{"greet", "name", "is required"}, perr)
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	var out []string
+	reg := greeter(&out)
+
+	_, err := reg.Unit("wave", nil)
+	assert.Equal(t, &ParamError{Unit: "wave", Msg: "is not registered"}, err)
+
+	_, err = reg.Phase("greet", map[string]interface{}{"name": "C", "times": 4})
+	assert.EqualError(t, err, `unit "greet": too many greetings`)
+}
+
+func TestParseParams(t *testing.T) {
+	var out []string
+	seq, err := Parse([]byte(`
+phases:
+  - unit: greet
+    params: {name: A, delay: 1ms}
+  - unit: greet
+    params:
+      name: B
+      times: 2
+`), greeter(&out))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stat := run(seq)
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, []string{"hello A", "hello B", "hello B"}, out)
+}
+
+func TestParseParamErrors(t *testing.T) {
+	var out []string
+	_, err := Parse([]byte(`
+phases:
+  - unit: greet
+    params:
+      times: many
+      colour: blue
+  - sequences: [{phases: [{unit: greet}]}]
+    params: {name: A}
+`), greeter(&out))
+
+	assert.EqualError(t, err, `$.phases[0].params.name (line 5, column 7): parameter is required
+$.phases[0].params.times (line 5, column 7): parameter must be of type int
+$.phases[0].params.colour (line 6, column 7): parameter is unknown
+$.phases[1].params (line 8, column 13): parameters without a unit
+$.phases[1].sequences[0].phases[0].params.name (line 7, column 34): parameter is required`)
+}
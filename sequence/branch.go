@@ -0,0 +1,190 @@
+package sequence
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nedp/command/status"
+)
+
+// A predicate which decides, when a run reaches an element,
+// whether the element runs.
+type condition func(context.Context) bool
+
+// Decides whether the element whose node is carried by `ctx` runs,
+// once the status is ready.
+//
+// An element which doesn't run is recorded as skipped.
+// In a dry run, the condition isn't checked, so that the plan
+// includes every alternative.
+//
+// Returns
+// whether the element should run, which is false if its condition
+// is false or the status failed.
+func (c condition) allows(ctx context.Context, stat status.Interface) bool {
+	if c == nil || isDryRun(ctx) {
+		return true
+	}
+	n := nodeFrom(ctx)
+	// Don't decide while paused, or after a failure.
+	if !stat.ReadyRLock() {
+		n.skip()
+		return false
+	}
+	stat.RUnlock()
+
+	if !c(ctx) {
+		n.bypass()
+		return false
+	}
+	return true
+}
+
+// The decisions of the chains of conditional phases of a sequence,
+// kept separately each time the sequence runs, so that instances
+// of the same chain don't see eachother's decisions.
+type decisions struct {
+	sync.Mutex
+	taken map[*int]bool
+}
+
+type decisionsKey struct{}
+
+// Returns
+// a copy of `ctx` which carries new, empty decisions.
+func withDecisions(ctx context.Context) context.Context {
+	return context.WithValue(ctx, decisionsKey{}, &decisions{taken: make(map[*int]bool)})
+}
+
+// Returns
+// the decisions carried by `ctx`, or detached decisions if there
+// are none.
+func decisionsFrom(ctx context.Context) *decisions {
+	if d, ok := ctx.Value(decisionsKey{}).(*decisions); ok {
+		return d
+	}
+	return &decisions{taken: make(map[*int]bool)}
+}
+
+// Records whether a branch of the conditional phases identified
+// by `key` was taken.
+func (d *decisions) decide(key *int, taken bool) {
+	d.Lock()
+	defer d.Unlock()
+	d.taken[key] = taken
+}
+
+// Returns
+// whether a branch of the conditional phases identified by `key`
+// was taken;
+// whether that was decided, which is false if the sequence didn't
+// reach them.
+func (d *decisions) decision(key *int) (bool, bool) {
+	d.Lock()
+	defer d.Unlock()
+	taken, ok := d.taken[key]
+	return taken, ok
+}
+
+// Returns
+// a condition which is true if `cond` is true and no earlier branch
+// identified by `prev` was taken, recording whether any branch was
+// taken with `key`. If `prev` is nil, there is no earlier branch.
+func branch(prev, key *int, cond func(context.Context) bool) condition {
+	return func(ctx context.Context) bool {
+		s := decisionsFrom(ctx)
+		if prev != nil {
+			taken, ok := s.decision(prev)
+			if !ok {
+				return false
+			}
+			if taken {
+				s.decide(key, true)
+				return false
+			}
+		}
+		taken := cond(ctx)
+		s.decide(key, taken)
+		return taken
+	}
+}
+
+// Appends a phase which runs only if `cond` returns true when the
+// sequence reaches it. Otherwise the phase is recorded as skipped,
+// and the sequence continues with the next phase.
+//
+// `cond` is passed the context of the run, so it may depend on the
+// Values and Results of earlier phases.
+// May be followed by ElseIf and Else to add alternatives.
+//
+// Returns
+// a copy of the reciever with the conditional phase added.
+func (sb SequenceBuilder) ThenIf(cond func(context.Context) bool, pb PhaseBuilder) SequenceBuilder {
+	return sb.thenBranch(nil, cond, pb)
+}
+
+// Appends a phase which runs only if none of the preceding phases
+// added with ThenIf or ElseIf ran, and `cond` returns true.
+//
+// Panics if the preceding phase wasn't added with ThenIf or ElseIf.
+//
+// Returns
+// a copy of the reciever with the conditional phase added.
+func (sb SequenceBuilder) ElseIf(cond func(context.Context) bool, pb PhaseBuilder) SequenceBuilder {
+	return sb.thenBranch(sb.lastBranch("ElseIf"), cond, pb)
+}
+
+// Appends a phase which runs only if none of the preceding phases
+// added with ThenIf or ElseIf ran.
+//
+// If the preceding phases weren't reached, because of a failure,
+// the phase is skipped too.
+// Panics if the preceding phase wasn't added with ThenIf or ElseIf.
+//
+// Returns
+// a copy of the reciever with the alternative phase added.
+func (sb SequenceBuilder) Else(pb PhaseBuilder) SequenceBuilder {
+	prev := sb.lastBranch("Else")
+	ph := pb.finish()
+	ph.cond = func(ctx context.Context) bool {
+		taken, ok := decisionsFrom(ctx).decision(prev)
+		return ok && !taken
+	}
+	sb.phases = append(sb.phases, ph)
+	return sb
+}
+
+// Adds a sub-sequence which runs only if `cond` returns true when
+// the phase starts it. Otherwise the sub-sequence is recorded as
+// skipped, and doesn't fail the phase.
+//
+// Returns
+// a copy of the reciever, but with the conditional sequence added.
+func (pb PhaseBuilder) AndIf(cond func(context.Context) bool, sb SequenceBuilder) PhaseBuilder {
+	seq := sb.finish()
+	seq.cond = cond
+	pb.sequences = append(pb.sequences, seq)
+	return pb
+}
+
+// Appends `pb` as a phase of a chain of conditional phases,
+// following the phase identified by `prev`, if any.
+func (sb SequenceBuilder) thenBranch(prev *int,
+	cond func(context.Context) bool, pb PhaseBuilder) SequenceBuilder {
+	key := new(int)
+	ph := pb.finish()
+	ph.cond = branch(prev, key, cond)
+	ph.branch = key
+	sb.phases = append(sb.phases, ph)
+	return sb
+}
+
+// Returns
+// the key identifying the last phase, which must have been added
+// with ThenIf or ElseIf by the caller, `method`.
+func (sb SequenceBuilder) lastBranch(method string) *int {
+	if n := len(sb.phases); n > 0 && sb.phases[n-1].branch != nil {
+		return sb.phases[n-1].branch
+	}
+	panic("sequence: " + method + " must follow ThenIf or ElseIf")
+}
@@ -0,0 +1,166 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+// Returns
+// a condition which is true if `v` was produced as `want`.
+func equals(v Value[int], want int) func(context.Context) bool {
+	return func(ctx context.Context) bool {
+		got, err := v.Get(ctx)
+		return err == nil && got == want
+	}
+}
+
+// Returns
+// a sequence which produces `x` as `v`, then runs one of three
+// branches depending on it, appending the name of the branch to `ran`.
+func branches(v Value[int], x int, ran *[]string) Sequence {
+	record := func(name string) PhaseBuilder {
+		return PhaseOf(func() error {
+			*ran = append(*ran, name)
+			return nil
+		}).Name(name)
+	}
+	return FirstJustCtx(func(ctx context.Context) error {
+		return v.Set(ctx, x)
+	}).
+		ThenIf(equals(v, 1), record("one")).
+		ElseIf(equals(v, 2), record("two")).
+		Else(record("other")).
+		Then(record("last")).
+		End()
+}
+
+func TestThenIf(t *testing.T) {
+	for x, want := range map[int][]string{
+		1: {"one", "last"},
+		2: {"two", "last"},
+		3: {"other", "last"},
+	} {
+		var ran []string
+		seq := branches(NewValue[int]("x"), x, &ran)
+
+		stat := seq.RunAll(status.New())
+
+		assert.False(t, stat.HasFailed())
+		assert.Equal(t, want, ran, x)
+	}
+}
+
+func TestThenIfSkippedRecorded(t *testing.T) {
+	var ran []string
+	seq := branches(NewValue[int]("x"), 2, &ran)
+
+	seq.RunAll(status.New())
+
+	assert.Equal(t, map[string]State{
+		"": Succeeded, "1": Succeeded,
+		"one": Skipped, "two": Succeeded, "other": Skipped,
+		"last": Succeeded,
+	}, states(seq.Snapshot()))
+}
+
+func TestElseAfterFailure(t *testing.T) {
+	failure := errors.New("A failure")
+	ran := false
+	seq := FirstJust(func() error {
+		return failure
+	}).
+		ThenIf(func(context.Context) bool { return false }, PhaseOf(succeed)).
+		Else(PhaseOf(func() error {
+			ran = true
+			return nil
+		})).
+		End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.True(t, stat.HasFailed())
+	assert.False(t, ran)
+	assert.Equal(t, map[string]State{
+		"": Failed, "1": Failed, "2": Skipped, "3": Skipped,
+	}, states(seq.Snapshot()))
+}
+
+func TestElseWithoutThenIf(t *testing.T) {
+	assert.Panics(t, func() {
+		FirstJust(succeed).Else(PhaseOf(succeed))
+	})
+	assert.Panics(t, func() {
+		FirstJust(succeed).
+			ThenIf(func(context.Context) bool { return true }, PhaseOf(succeed)).
+			Else(PhaseOf(succeed)).
+			Else(PhaseOf(succeed))
+	})
+}
+
+func TestAndIf(t *testing.T) {
+	never := func(context.Context) bool { return false }
+	always := func(context.Context) bool { return true }
+	var nRan int
+	count := func() error {
+		nRan += 1
+		return nil
+	}
+	seq := PhaseOf(succeed).
+		AndIf(never, FirstJust(count)).
+		AndIf(always, FirstJust(count)).
+		End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, 1, nRan)
+	assert.Equal(t, map[string]State{
+		"": Succeeded, "1": Succeeded,
+		"1/1": Skipped, "1/1/1": Skipped,
+		"1/2": Succeeded, "1/2/1": Succeeded,
+	}, states(seq.Snapshot()))
+}
+
+func TestThenIfInstancesSeparate(t *testing.T) {
+	var mu sync.Mutex
+	ran := make(map[string][]string)
+	record := func(name string) PhaseBuilder {
+		return PhaseOfCtx(func(ctx context.Context) error {
+			time.Sleep(milliDuration)
+			mu.Lock()
+			defer mu.Unlock()
+			instance := strings.SplitN(nodeFrom(ctx).path, "/", 3)[1]
+			ran[instance] = append(ran[instance], name)
+			return nil
+		})
+	}
+	var nDecided int32
+	firstOnly := func(context.Context) bool {
+		return atomic.AddInt32(&nDecided, 1) == 1
+	}
+	sb := FirstJust(succeed).
+		ThenIf(firstOnly, record("then")).
+		Else(record("else"))
+
+	stat := PhaseOf(succeed).And(sb).And(sb).End().RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.Len(t, ran, 2)
+	var all []string
+	for instance, names := range ran {
+		assert.Len(t, names, 1, instance)
+		all = append(all, names...)
+	}
+	sort.Strings(all)
+	assert.Equal(t, []string{"else", "then"}, all)
+}
@@ -1,8 +1,20 @@
 package sequence
 
+import (
+	"context"
+	"time"
+)
+
 const defaultNSequences = 4
 const defaultNPhases = 4
 
+// A Unit is a function run as a unit of computation.
+//
+// It is passed a context which is cancelled when the status records
+// a failure, and an Emitter which sends its output to the sequence's
+// output channel, attributed to the unit.
+type Unit func(ctx context.Context, out *Emitter) error
+
 ////////////////////////////////////////////////////////////
 // Phase builder
 ////////////////////////////////////////////////////////////
@@ -21,8 +33,19 @@ const defaultNPhases = 4
 //
 // It will then block until all of its sequences have completed.
 type PhaseBuilder struct {
-	main func() error
+	name string
+	main Unit
 	sequences []sequence
+	timeout time.Duration
+	retry RetryPolicy
+	policy FailurePolicy
+	quorum int
+	maxParallel int
+	compensate func(context.Context) error
+	hooks Hooks
+	middleware []Middleware
+	expand func(context.Context) ([]runAller, error)
+	description func(context.Context) string
 }
 
 // Starts building a phase with `fn` as its main function.
@@ -30,7 +53,46 @@ type PhaseBuilder struct {
 // Returns
 // a phase builder with the specified main function.
 func PhaseOf(fn func() error) PhaseBuilder {
-	return PhaseBuilder{fn, make([]sequence, 0, defaultNSequences)}
+	return PhaseOfCtx(ignoreCtx(fn))
+}
+
+// Starts building a phase with `fn` as its main function.
+//
+// `fn` is passed a context which is cancelled when the status
+// records a failure.
+//
+// Returns
+// a phase builder with the specified main function.
+func PhaseOfCtx(fn func(context.Context) error) PhaseBuilder {
+	return PhaseOfUnit(func(ctx context.Context, _ *Emitter) error {
+		return fn(ctx)
+	})
+}
+
+// Starts building a phase with the unit `fn` as its main function.
+//
+// Returns
+// a phase builder with the specified main function.
+func PhaseOfUnit(fn Unit) PhaseBuilder {
+	return PhaseBuilder{
+		main: fn,
+		sequences: make([]sequence, 0, defaultNSequences),
+	}
+}
+
+// Starts building a phase which has no main function, and only runs
+// its sub-sequences: `sbs`, and those added later, such as with And.
+//
+// No unit is reported for the phase, in its events or in a dry run.
+//
+// Returns
+// a phase builder without a main function.
+func PhaseOfSequences(sbs ...SequenceBuilder) PhaseBuilder {
+	pb := PhaseOfUnit(nil)
+	for _, sb := range sbs {
+		pb = pb.And(sb)
+	}
+	return pb
 }
 
 // Adds a sequence to the to the phase.
@@ -41,10 +103,8 @@ func PhaseOf(fn func() error) PhaseBuilder {
 // a copy of the reciever, but with the specified sequence added.
 func (pb PhaseBuilder) And(sb SequenceBuilder) PhaseBuilder {
 	seq := sb.finish()
-	return PhaseBuilder{
-		main: pb.main,
-		sequences: append(pb.sequences, seq),
-	}
+	pb.sequences = append(pb.sequences, seq)
+	return pb
 }
 
 // Adds the function `fn`, to be run concurrently with
@@ -58,18 +118,224 @@ func (pb PhaseBuilder) AndJust(fn func() error) PhaseBuilder {
 	return pb.And(FirstJust(fn))
 }
 
+// Adds the function `fn`, to be run concurrently with
+// other specified functions.
+//
+// The function will have its own sub-sequence, and is passed
+// a context which is cancelled when the status records a failure.
+//
+// Returns
+// a copy of the reciever, but with `fn` added.
+func (pb PhaseBuilder) AndJustCtx(fn func(context.Context) error) PhaseBuilder {
+	return pb.And(FirstJustCtx(fn))
+}
+
+// Adds the unit `fn`, to be run concurrently with
+// other specified functions.
+//
+// The unit will have its own sub-sequence.
+//
+// Returns
+// a copy of the reciever, but with `fn` added.
+func (pb PhaseBuilder) AndJustUnit(fn Unit) PhaseBuilder {
+	return pb.And(FirstJustUnit(fn))
+}
+
+// Names the phase.
+//
+// The name identifies the phase in the paths used to attribute
+// its errors and output, in place of its position in its sequence.
+// Names shouldn't contain "/", and should be unique among the
+// phase's siblings so that its path is too.
+//
+// Returns
+// a copy of the reciever, but with the name set.
+func (pb PhaseBuilder) Name(name string) PhaseBuilder {
+	pb.name = name
+	return pb
+}
+
+// Limits the time each attempt of the phase's main function may take.
+//
+// If the main function hasn't returned after `d`, its context is
+// cancelled and a failure is recorded with a `*TimeoutError`,
+// without waiting any longer for the function to return.
+// The phase's sub-sequences aren't limited.
+//
+// Returns
+// a copy of the reciever, but with the time limit set.
+func (pb PhaseBuilder) Timeout(d time.Duration) PhaseBuilder {
+	pb.timeout = d
+	return pb
+}
+
+// Retries the phase's main function according to `rp`.
+//
+// The main function only fails the phase once the policy stops
+// retrying it. Each failed attempt is reported to the sequence's
+// output channel. Delays between attempts wait for the status to
+// be continued if it is paused, and end early if it fails.
+// A time limit set with Timeout applies to each attempt.
+//
+// Returns
+// a copy of the reciever, but with the retry policy set.
+func (pb PhaseBuilder) Retry(rp RetryPolicy) PhaseBuilder {
+	pb.retry = rp
+	return pb
+}
+
+// Limits the number of the phase's sub-sequences which run at once
+// to `n`.
+//
+// The other sub-sequences wait for one to finish before starting,
+// and don't start while the status is paused or after it fails.
+//
+// Returns
+// a copy of the reciever, but with the limit set.
+func (pb PhaseBuilder) MaxParallel(n int) PhaseBuilder {
+	pb.maxParallel = n
+	return pb
+}
+
+// Sets `undo` as the compensation for the phase's main function.
+//
+// If the computation fails after the main function has completed
+// successfully, `undo` is called to reverse its effects.
+// Compensations are called in the reverse of the order in which
+// their functions completed; those of functions which ran
+// concurrently are called concurrently.
+// A failure of `undo` is recorded as a `*CompensationError`.
+//
+// Compensations are only called when the whole run fails. Failures
+// which are tolerated or isolated, such as with ContinueOnError,
+// Isolate or a quorum, don't cause any functions to be compensated,
+// including those of the failed phases' sub-sequences.
+//
+// Returns
+// a copy of the reciever, but with the compensation set.
+func (pb PhaseBuilder) Compensate(undo func() error) PhaseBuilder {
+	return pb.CompensateCtx(ignoreCtx(undo))
+}
+
+// Like Compensate, but `undo` is passed a context which isn't
+// cancelled by the failure which it is compensating for.
+//
+// Returns
+// a copy of the reciever, but with the compensation set.
+func (pb PhaseBuilder) CompensateCtx(undo func(context.Context) error) PhaseBuilder {
+	pb.compensate = undo
+	return pb
+}
+
+// Sets the phase's failure policy, which is FailFast by default.
+//
+// Returns
+// a copy of the reciever, but with the failure policy set.
+func (pb PhaseBuilder) Policy(p FailurePolicy) PhaseBuilder {
+	pb.policy = p
+	return pb
+}
+
+// Requires at least `n` of the phase's sub-sequences to succeed.
+//
+// The sub-sequences are isolated from eachother and the phase.
+// Once they are all done, the phase fails with a `*QuorumError`
+// if fewer than `n` succeeded; otherwise their errors are only
+// recorded. If the phase has fewer than `n` sub-sequences when it
+// runs, the quorum is unreachable, and the phase fails once they
+// are done.
+//
+// Panics if `n` is negative.
+//
+// Returns
+// a copy of the reciever, but with the quorum set.
+func (pb PhaseBuilder) Quorum(n int) PhaseBuilder {
+	if n < 0 {
+		panic("sequence: Quorum must not be negative")
+	}
+	pb.quorum = n
+	return pb
+}
+
+// Sets `fn` to be run before the phase starts.
+//
+// If `fn` returns an error, the phase fails with a `*HookError`
+// without being run. See `Hooks`.
+//
+// Returns
+// a copy of the reciever, but with the hook set.
+func (pb PhaseBuilder) Before(fn func(context.Context) error) PhaseBuilder {
+	pb.hooks.Before = fn
+	return pb
+}
+
+// Sets `fn` to be run after the phase succeeds.
+//
+// If `fn` returns an error, the phase fails with a `*HookError`.
+//
+// Returns
+// a copy of the reciever, but with the hook set.
+func (pb PhaseBuilder) After(fn func(context.Context) error) PhaseBuilder {
+	pb.hooks.After = fn
+	return pb
+}
+
+// Sets `fn` to be run with the cause of the phase's failure,
+// if it fails.
+//
+// Returns
+// a copy of the reciever, but with the hook set.
+func (pb PhaseBuilder) OnError(fn func(context.Context, error)) PhaseBuilder {
+	pb.hooks.OnError = fn
+	return pb
+}
+
+// Sets `fn` to be run once the phase has finished, even if the
+// status has failed.
+//
+// Returns
+// a copy of the reciever, but with the hook set.
+func (pb PhaseBuilder) Finally(fn func(context.Context)) PhaseBuilder {
+	pb.hooks.Finally = fn
+	return pb
+}
+
+// Wraps the phase's main function, and every unit in its
+// sub-sequences, with `mws`.
+//
+// The first middleware is outermost. Middleware registered by the
+// phase's enclosing sequences wraps the phase's middleware.
+//
+// Returns
+// a copy of the reciever, but with the middleware added.
+func (pb PhaseBuilder) Use(mws ...Middleware) PhaseBuilder {
+	pb.middleware = append(pb.middleware[:len(pb.middleware):len(pb.middleware)], mws...)
+	return pb
+}
+
 // Finishes building so the computation may be run.
 //
 // Returns
 // a runnable `Sequence` object containing the specified
 // main function and sub-sequences.
-func (pb PhaseBuilder) End(output <-chan string) Sequence {
-	return SequenceOf(pb).End(output)
+func (pb PhaseBuilder) End() Sequence {
+	return SequenceOf(pb).End()
 }
 
 func (pb PhaseBuilder) finish() phase {
 	ph := phase{}
+	ph.name = pb.name
 	ph.main = pb.main
+	ph.timeout = pb.timeout
+	ph.retry = pb.retry
+	ph.policy = pb.policy
+	ph.quorum = pb.quorum
+	ph.maxParallel = pb.maxParallel
+	ph.compensate = pb.compensate
+	ph.hooks = pb.hooks
+	ph.middleware = pb.middleware
+	ph.expand = pb.expand
+	ph.description = pb.description
 	ph.sequences = make([]runAller, len(pb.sequences))
 	for i, seq := range pb.sequences {
 		ph.sequences[i] = runAller(seq)
@@ -93,7 +359,15 @@ func (pb PhaseBuilder) finish() phase {
 // in the goroutine (though these phases may spawn additional
 // goroutines to do their own computation).
 //
-type SequenceBuilder []phase
+type SequenceBuilder struct {
+	name string
+	phases []phase
+	deadline time.Time
+	timeout time.Duration
+	policy FailurePolicy
+	hooks Hooks
+	middleware []Middleware
+}
 
 // Starts building a sequence from a single phase.
 //
@@ -105,7 +379,7 @@ func SequenceOf(pb PhaseBuilder) SequenceBuilder {
 	ph := pb.finish()
 	phases := make([]phase, 1, defaultNPhases)
 	phases[0] = ph
-	return SequenceBuilder(phases)
+	return SequenceBuilder{phases: phases}
 }
 
 // Starts building a sequence with a function `fn`.
@@ -118,6 +392,27 @@ func FirstJust(fn func() error) SequenceBuilder {
 	return SequenceOf(PhaseOf(fn))
 }
 
+// Starts building a sequence with a function `fn`.
+//
+// `fn` will be contained in its own sub-phase, and is passed
+// a context which is cancelled when the status records a failure.
+//
+// Returns
+// a builder for a new sequence with a single phase containing `fn`.
+func FirstJustCtx(fn func(context.Context) error) SequenceBuilder {
+	return SequenceOf(PhaseOfCtx(fn))
+}
+
+// Starts building a sequence with a unit `fn`.
+//
+// `fn` will be contained in its own sub-phase.
+//
+// Returns
+// a builder for a new sequence with a single phase containing `fn`.
+func FirstJustUnit(fn Unit) SequenceBuilder {
+	return SequenceOf(PhaseOfUnit(fn))
+}
+
 // Appends a phase to a sequence.
 //
 // `pb` is the builder for the phase to be appended.
@@ -126,7 +421,8 @@ func FirstJust(fn func() error) SequenceBuilder {
 // a copy of the reciever with the specified phase added.
 func (sb SequenceBuilder) Then(pb PhaseBuilder) SequenceBuilder {
 	ph := pb.finish()
-	return SequenceBuilder(append([]phase(sb), ph))
+	sb.phases = append(sb.phases, ph)
+	return sb
 }
 
 // Appends a function `fn` to the sequence.
@@ -139,19 +435,165 @@ func (sb SequenceBuilder) ThenJust(fn func() error) SequenceBuilder {
 	return sb.Then(PhaseOf(fn))
 }
 
+// Appends a function `fn` to the sequence.
+//
+// `fn` will be contained in its own sub-phase, and is passed
+// a context which is cancelled when the status records a failure.
+//
+// Returns
+// a copy of the reciever with a phase containing `fn` added.
+func (sb SequenceBuilder) ThenJustCtx(fn func(context.Context) error) SequenceBuilder {
+	return sb.Then(PhaseOfCtx(fn))
+}
+
+// Appends a unit `fn` to the sequence.
+//
+// `fn` will be contained in its own sub-phase.
+//
+// Returns
+// a copy of the reciever with a phase containing `fn` added.
+func (sb SequenceBuilder) ThenJustUnit(fn Unit) SequenceBuilder {
+	return sb.Then(PhaseOfUnit(fn))
+}
+
+// Names the sequence.
+//
+// The name identifies the sequence in the paths used to attribute
+// errors and output, in place of its position in its phase.
+// The name of the sequence passed to End prefixes every path.
+// Names shouldn't contain "/", and should be unique among the
+// sequence's siblings so that its path is too.
+//
+// Returns
+// a copy of the reciever with the name set.
+func (sb SequenceBuilder) Name(name string) SequenceBuilder {
+	sb.name = name
+	return sb
+}
+
+// Sets a deadline by which the whole sequence must finish.
+//
+// If the sequence is still running at `t`, the context of its
+// functions is cancelled and a failure is recorded with a
+// `*TimeoutError`. No more phases are started, but the sequence
+// waits for its running functions to return, so functions should
+// respect their context or have their own Timeout.
+//
+// Returns
+// a copy of the reciever with the deadline set.
+func (sb SequenceBuilder) Deadline(t time.Time) SequenceBuilder {
+	sb.deadline = t
+	return sb
+}
+
+// Limits the time the whole sequence may take each time it runs.
+//
+// Behaves like Deadline, with the deadline set to `d` after
+// the sequence starts.
+//
+// Returns
+// a copy of the reciever with the time limit set.
+func (sb SequenceBuilder) Timeout(d time.Duration) SequenceBuilder {
+	sb.timeout = d
+	return sb
+}
+
+// Sets the sequence's failure policy, which is FailFast by default.
+//
+// Returns
+// a copy of the reciever with the failure policy set.
+func (sb SequenceBuilder) Policy(p FailurePolicy) SequenceBuilder {
+	sb.policy = p
+	return sb
+}
+
+// Sets `fn` to be run before the sequence starts.
+//
+// If `fn` returns an error, the sequence fails with a `*HookError`
+// without being run. See `Hooks`.
+//
+// Returns
+// a copy of the reciever with the hook set.
+func (sb SequenceBuilder) Before(fn func(context.Context) error) SequenceBuilder {
+	sb.hooks.Before = fn
+	return sb
+}
+
+// Sets `fn` to be run after the sequence succeeds.
+//
+// If `fn` returns an error, the sequence fails with a `*HookError`.
+//
+// Returns
+// a copy of the reciever with the hook set.
+func (sb SequenceBuilder) After(fn func(context.Context) error) SequenceBuilder {
+	sb.hooks.After = fn
+	return sb
+}
+
+// Sets `fn` to be run with the cause of the sequence's failure,
+// if it fails.
+//
+// Returns
+// a copy of the reciever with the hook set.
+func (sb SequenceBuilder) OnError(fn func(context.Context, error)) SequenceBuilder {
+	sb.hooks.OnError = fn
+	return sb
+}
+
+// Sets `fn` to be run once the sequence has finished, even if the
+// status has failed.
+//
+// Returns
+// a copy of the reciever with the hook set.
+func (sb SequenceBuilder) Finally(fn func(context.Context)) SequenceBuilder {
+	sb.hooks.Finally = fn
+	return sb
+}
+
+// Wraps every unit in the sequence with `mws`.
+//
+// The first middleware is outermost. Middleware registered by the
+// sequence's enclosing phases and sequences wraps the sequence's
+// middleware.
+//
+// Returns
+// a copy of the reciever with the middleware added.
+func (sb SequenceBuilder) Use(mws ...Middleware) SequenceBuilder {
+	sb.middleware = append(sb.middleware[:len(sb.middleware):len(sb.middleware)], mws...)
+	return sb
+}
+
 // Finishes building so the computation may be run.
 //
+// The sequence owns its output channel, to which its units and
+// its progress, such as the retries of failed functions, are
+// reported. Output is dropped unless it is read from the channel.
+// See `Sequence.Records`.
+//
 // Returns
 // a runnable `Sequence` containing the specified phases.
-func (sb SequenceBuilder) End(output <-chan string) Sequence {
-	return Sequence{make(chan bool, 1), sb.finish(), output}
+func (sb SequenceBuilder) End() Sequence {
+	return Sequence{make(chan bool, 1), sb.finish(), new(outlet), new(progress)}
 }
 
 func (sb SequenceBuilder) finish() sequence {
 	seq := sequence{}
-	seq.phases = make([]runAller, len([]phase(sb)))
-	for i, ph := range []phase(sb) {
+	seq.name = sb.name
+	seq.deadline = sb.deadline
+	seq.timeout = sb.timeout
+	seq.policy = sb.policy
+	seq.hooks = sb.hooks
+	seq.middleware = sb.middleware
+	seq.phases = make([]runAller, len(sb.phases))
+	for i, ph := range sb.phases {
 		seq.phases[i] = runAller(ph)
 	}
 	return seq
 }
+
+// Adapts a function which doesn't use a context to one which does.
+func ignoreCtx(fn func() error) func(context.Context) error {
+	return func(context.Context) error {
+		return fn()
+	}
+}
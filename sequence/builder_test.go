@@ -1,9 +1,16 @@
 package sequence
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"sync/atomic"
+	"time"
 	"errors"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
 )
 
 func TestBuildBasic(t *testing.T) {
@@ -58,7 +65,7 @@ func TestBuildBasic(t *testing.T) {
 				}),
 			),
 		),
-	).End(out)
+	).End()
 
 	printFullSequence(seq, out)
 }
@@ -99,7 +106,7 @@ func TestBuildingCompact(t *testing.T) {
 			out <- "B2"
 			return nil
 		}),
-	).End(out)
+	).End()
 
 	printFullSequence(seq, out)
 }
@@ -140,11 +147,97 @@ func TestFailure(t *testing.T) {
 			out <- "B2"
 			return nil
 		}),
-	).End(out)
+	).End()
 
 	printFullSequence(seq, out)
 }
 
+func TestCtxCancelledOnFailure(t *testing.T) {
+	failure := errors.New("A failure")
+	seq := PhaseOf(func() error {
+		return failure
+	}).AndJustCtx(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}).End()
+
+	// The cancellation of the sub-sequence isn't reported as a failure.
+	stat := seq.RunAll(status.New())
+	assert.Equal(t, &UnitError{"1", failure}, stat.Err())
+}
+
+func TestOwnErrorKeptAfterCancellation(t *testing.T) {
+	failureA := errors.New("A failure")
+	failureB := errors.New("B failure")
+	started := make(chan struct{})
+	seq := PhaseOf(func() error {
+		<-started
+		return failureA
+	}).AndJustCtx(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		// Fails for its own reason after the cancellation.
+		return failureB
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.ErrorIs(t, stat.Err(), failureA)
+	assert.ErrorIs(t, stat.Err(), failureB)
+}
+
+func TestPhaseTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	seq := PhaseOf(func() error {
+		// Ignores its context, so must be abandoned.
+		<-hang
+		return nil
+	}).Timeout(milliDuration).End()
+
+	start := time.Now()
+	stat := seq.RunAll(status.New())
+
+	assert.WithinDuration(t, start.Add(milliDuration), time.Now(), tinyDuration,
+		"The timeout didn't end the phase")
+	var timeout *TimeoutError
+	assert.ErrorAs(t, stat.Err(), &timeout)
+	assert.ErrorIs(t, stat.Err(), context.DeadlineExceeded)
+}
+
+func TestSequenceDeadline(t *testing.T) {
+	deadline := time.Now().Add(milliDuration)
+	seq := FirstJustCtx(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}).ThenJust(func() error {
+		t.Error("A phase ran after the deadline")
+		return nil
+	}).Deadline(deadline).End()
+
+	stat := seq.RunAll(status.New())
+
+	// Only the deadline is reported, not the function's response to it.
+	assert.Equal(t, &TimeoutError{deadline}, stat.Err())
+}
+
+func TestSequenceDeadlineWaitsForPhases(t *testing.T) {
+	var returned atomic.Bool
+	seq := FirstJustCtx(func(ctx context.Context) error {
+		<-ctx.Done()
+		// Slow to respond to the deadline.
+		time.Sleep(milliDuration)
+		returned.Store(true)
+		return nil
+	}).Deadline(time.Now().Add(milliDuration)).End()
+
+	stat := seq.RunAll(status.New())
+
+	var timeout *TimeoutError
+	assert.ErrorAs(t, stat.Err(), &timeout)
+	assert.True(t, returned.Load(), "The sequence finished before its phase returned")
+}
+
 func TestFailureSinglePhase(t *testing.T) {
 	out := make(chan string, 1)
 	seq := PhaseOf(func() error {
@@ -179,7 +272,7 @@ func TestFailureSinglePhase(t *testing.T) {
 			out <- "A2d"
 			return nil
 		}),
-	).End(out)
+	).End()
 
 	printFullSequence(seq, out)
 }
@@ -195,7 +288,7 @@ func TestShortSinglePhase(t *testing.T) {
 	}).AndJust(func() error {
 		out <- "C"
 		return nil
-	}).End(out)
+	}).End()
 
 	printFullSequence(seq, out)
 }
@@ -257,7 +350,7 @@ func TestSinglePhase(t *testing.T) {
 				),
 			),
 		),
-	).End(out)
+	).End()
 
 	printFullSequence(seq, out)
 }
@@ -303,7 +396,7 @@ func TestCompactSinglePhase(t *testing.T) {
 				return nil
 			}),
 		),
-	).End(out)
+	).End()
 
 	printFullSequence(seq, out)
 }
@@ -314,7 +407,7 @@ func printPhase(ph phase, prefix string, out <-chan string) bool {
 	nextPrefix := fmt.Sprintf("%s|  ", prefix)
 	fmt.Printf("\n%s+--", prefix)
 
-	err := ph.main()
+	err := ph.run(context.Background())
 	print(<-out)
 
 	if err != nil {
@@ -0,0 +1,106 @@
+package sequence
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nedp/command/status"
+)
+
+// CompensationError is recorded when a compensating function,
+// run after a failure, returns an error.
+type CompensationError struct {
+	// The path of the unit being compensated for.
+	Path string
+	Err error
+}
+
+func (e *CompensationError) Error() string {
+	if e.Path == "" {
+		return "compensation failed: " + e.Err.Error()
+	}
+	return "compensation of " + e.Path + " failed: " + e.Err.Error()
+}
+
+func (e *CompensationError) Unwrap() error {
+	return e.Err
+}
+
+// Records that the element's function completed, so that `undo`
+// must be run if the computation fails. Does nothing if `undo` is nil.
+func (n *node) completed(undo func(context.Context) error) {
+	n.Lock()
+	defer n.Unlock()
+	n.undo = undo
+}
+
+// Whether the node or any of its descendants has a compensation.
+func (n *node) hasUndo() bool {
+	n.Lock()
+	defer n.Unlock()
+	if n.undo != nil {
+		return true
+	}
+	for _, c := range n.children {
+		if c.hasUndo() {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs the compensations of the node and its descendants,
+// in the reverse of the order in which they completed.
+//
+// Children which ran concurrently are compensated concurrently,
+// along with the node's own compensation; otherwise the children
+// are compensated one at a time, starting from the last.
+// Failures of compensations are recorded in `stat` as
+// `*CompensationError`s, but don't stop other compensations.
+func (n *node) compensate(ctx context.Context, stat status.Interface) {
+	n.Lock()
+	undo := n.undo
+	children := append([]*node(nil), n.children...)
+	n.Unlock()
+
+	if !n.concurrent {
+		for i := len(children) - 1; i >= 0; i -= 1 {
+			children[i].compensate(ctx, stat)
+		}
+		n.runUndo(ctx, stat, undo)
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(children))
+	for _, c := range children {
+		go func(c *node) {
+			defer wg.Done()
+			c.compensate(ctx, stat)
+		}(c)
+	}
+	n.runUndo(ctx, stat, undo)
+	wg.Wait()
+}
+
+// Runs `undo`, the node's compensation, if it isn't nil.
+//
+// Marks the node as compensated if `undo` succeeds;
+// otherwise records its failure in `stat` and on the node.
+func (n *node) runUndo(ctx context.Context, stat status.Interface,
+	undo func(context.Context) error) {
+	if undo == nil {
+		return
+	}
+	err := protect(undo)(ctx)
+
+	n.Lock()
+	defer n.Unlock()
+	if err != nil {
+		err = &CompensationError{n.path, err}
+		_ = stat.FailWith(err)
+		n.state, n.err = Failed, err
+		return
+	}
+	n.state = Compensated
+}
@@ -0,0 +1,120 @@
+package sequence
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+// Records the order in which compensations were called.
+type undoLog struct {
+	sync.Mutex
+	names []string
+}
+
+func (l *undoLog) undo(name string, err error) func() error {
+	return func() error {
+		l.Lock()
+		defer l.Unlock()
+		l.names = append(l.names, name)
+		return err
+	}
+}
+
+func succeed() error {
+	return nil
+}
+
+func TestCompensateInReverse(t *testing.T) {
+	log := new(undoLog)
+	failure := errors.New("A failure")
+	seq := SequenceOf(
+		PhaseOf(succeed).Compensate(log.undo("A", nil)),
+	).Then(
+		PhaseOf(succeed).Compensate(log.undo("B", nil)),
+	).Then(
+		PhaseOf(func() error {
+			return failure
+		}).Compensate(log.undo("C", nil)),
+	).Then(
+		PhaseOf(succeed).Compensate(log.undo("D", nil)),
+	).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.Equal(t, &UnitError{"3", failure}, stat.Err())
+	assert.Equal(t, []string{"B", "A"}, log.names)
+}
+
+func TestCompensateConcurrent(t *testing.T) {
+	log := new(undoLog)
+	seq := SequenceOf(
+		PhaseOf(succeed).Compensate(log.undo("A", nil)).And(
+			SequenceOf(
+				PhaseOf(succeed).Compensate(log.undo("A1a", nil)),
+			).Then(
+				PhaseOf(succeed).Compensate(log.undo("A1b", nil)),
+			),
+		).And(
+			SequenceOf(PhaseOf(succeed).Compensate(log.undo("A2", nil))),
+		),
+	).ThenJust(func() error {
+		return errors.New("A failure")
+	}).End()
+
+	seq.RunAll(status.New())
+
+	// A1b must be undone before A1a, but the rest are concurrent.
+	assert.Len(t, log.names, 4)
+	iA1a, iA1b := -1, -1
+	for i, name := range log.names {
+		switch name {
+		case "A1a":
+			iA1a = i
+		case "A1b":
+			iA1b = i
+		}
+	}
+	assert.True(t, iA1b < iA1a, "A1a was undone before A1b")
+	sorted := append([]string(nil), log.names...)
+	sort.Strings(sorted)
+	assert.Equal(t, []string{"A", "A1a", "A1b", "A2"}, sorted)
+}
+
+func TestCompensationFailure(t *testing.T) {
+	log := new(undoLog)
+	failure := errors.New("A failure")
+	undoFailure := errors.New("An undo failure")
+	seq := SequenceOf(
+		PhaseOf(succeed).Compensate(log.undo("A", nil)),
+	).Then(
+		PhaseOf(succeed).Compensate(log.undo("B", undoFailure)),
+	).ThenJust(func() error {
+		return failure
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	// Both the original failure and the compensation's are reported.
+	assert.Equal(t, []string{"B", "A"}, log.names)
+	assert.ErrorIs(t, stat.Err(), failure)
+	var compErr *CompensationError
+	if assert.ErrorAs(t, stat.Err(), &compErr) {
+		assert.Equal(t, undoFailure, compErr.Err)
+	}
+}
+
+func TestNoCompensationOnSuccess(t *testing.T) {
+	log := new(undoLog)
+	seq := PhaseOf(succeed).Compensate(log.undo("A", nil)).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.Nil(t, stat.Err())
+	assert.Empty(t, log.names)
+}
@@ -0,0 +1,149 @@
+package sequence
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nedp/command/status"
+)
+
+// A semaphore limiting how many tasks run at once.
+type semaphore chan struct{}
+
+// Waits for a free slot, while the status isn't paused.
+//
+// `stat` may be nil, in which case pauses aren't waited for.
+//
+// Returns
+// whether a slot was acquired, which is false if `ctx` is
+// cancelled first.
+func (s semaphore) acquire(ctx context.Context, stat status.Interface) bool {
+	for {
+		if stat != nil {
+			select {
+			case <-stat.Unpaused():
+			case <-ctx.Done():
+				return false
+			}
+		}
+		select {
+		case s <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+		// The status may have been paused while waiting for the slot.
+		if stat == nil || !stat.IsPaused() {
+			return true
+		}
+		s.release()
+	}
+}
+
+// Frees a slot acquired with acquire.
+func (s semaphore) release() {
+	<-s
+}
+
+// Workers limits how many units of computation run at once.
+//
+// A Workers may be shared by many runs, and many commands,
+// to limit the units they run in total.
+type Workers struct {
+	// nil if there's no limit.
+	slots semaphore
+}
+
+// Creates a limit of `n` units running at once.
+// If `n` isn't positive, there is no limit.
+//
+// Returns
+// the new Workers.
+func NewWorkers(n int) *Workers {
+	if n <= 0 {
+		return &Workers{}
+	}
+	return &Workers{make(semaphore, n)}
+}
+
+type workersKey struct{}
+
+// Returns
+// a copy of `ctx` which carries `w`, or `ctx` if `w` is nil
+// or has no limit.
+func withWorkers(ctx context.Context, w *Workers) context.Context {
+	if w == nil || w.slots == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, workersKey{}, w)
+}
+
+// Returns
+// the Workers carried by `ctx`, or nil if there are none.
+func workersFrom(ctx context.Context) *Workers {
+	w, _ := ctx.Value(workersKey{}).(*Workers)
+	return w
+}
+
+// A worker slot held by a running unit of computation.
+//
+// The unit gives up the slot while it waits for a Value, so that
+// the units producing the value can run.
+type hold struct {
+	sync.Mutex
+	slots semaphore
+	held bool
+}
+
+type holdKey struct{}
+
+// Waits for a slot of `w`, as for acquire.
+//
+// Returns
+// a copy of `ctx` which carries the hold, and the hold,
+// which must be freed once the unit has finished;
+// nil if `ctx` was cancelled first.
+func (w *Workers) hold(ctx context.Context, stat status.Interface) (context.Context, *hold) {
+	if !w.slots.acquire(ctx, stat) {
+		return ctx, nil
+	}
+	h := &hold{slots: w.slots, held: true}
+	return context.WithValue(ctx, holdKey{}, h), h
+}
+
+// Returns
+// the hold carried by `ctx`, or nil if there is none.
+func holdFrom(ctx context.Context) *hold {
+	h, _ := ctx.Value(holdKey{}).(*hold)
+	return h
+}
+
+// Gives up the slot, if it is held.
+func (h *hold) free() {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	if h.held {
+		h.slots.release()
+		h.held = false
+	}
+}
+
+// Waits for the slot again, if it was given up.
+//
+// Returns
+// whether the slot is held, which is false if `ctx` is
+// cancelled first.
+func (h *hold) resume(ctx context.Context) bool {
+	if h == nil {
+		return true
+	}
+	h.Lock()
+	defer h.Unlock()
+	if !h.held {
+		stat, _ := statusFrom(ctx)
+		h.held = h.slots.acquire(ctx, stat)
+	}
+	return h.held
+}
@@ -0,0 +1,129 @@
+package sequence
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+// Returns
+// a function which records the greatest number of its calls
+// running at once in `max`.
+func counting(running, max *int32) func() error {
+	return func() error {
+		n := atomic.AddInt32(running, 1)
+		defer atomic.AddInt32(running, -1)
+		for {
+			m := atomic.LoadInt32(max)
+			if n <= m || atomic.CompareAndSwapInt32(max, m, n) {
+				break
+			}
+		}
+		time.Sleep(milliDuration)
+		return nil
+	}
+}
+
+func TestMaxParallel(t *testing.T) {
+	var running, max int32
+	pb := PhaseOf(succeed).MaxParallel(2)
+	for i := 0; i < 6; i += 1 {
+		pb = pb.AndJust(counting(&running, &max))
+	}
+
+	stat := pb.End().RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, int32(2), max)
+}
+
+func TestMaxParallelStoppedWhileQueued(t *testing.T) {
+	stat := status.New()
+	var nRan int32
+	pb := PhaseOf(succeed).MaxParallel(1)
+	for i := 0; i < 3; i += 1 {
+		pb = pb.AndJust(func() error {
+			atomic.AddInt32(&nRan, 1)
+			time.Sleep(tinyDuration)
+			return nil
+		})
+	}
+	seq := pb.End()
+
+	go func() {
+		time.Sleep(milliDuration)
+		stat.Fail()
+	}()
+	seq.RunAll(stat)
+
+	assert.Equal(t, int32(1), nRan)
+	nSkipped := 0
+	for _, c := range seq.Snapshot().Children[0].Children {
+		if c.State == Skipped {
+			nSkipped += 1
+		}
+	}
+	assert.Equal(t, 2, nSkipped)
+}
+
+func TestMaxParallelPaused(t *testing.T) {
+	stat := status.New()
+	var nRan int32
+	pb := PhaseOf(succeed).MaxParallel(1)
+	for i := 0; i < 2; i += 1 {
+		pb = pb.AndJust(func() error {
+			atomic.AddInt32(&nRan, 1)
+			time.Sleep(tinyDuration)
+			return nil
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pb.End().RunAll(stat)
+		close(done)
+	}()
+	time.Sleep(milliDuration)
+	stat.Pause()
+
+	// The queued sub-sequence doesn't start while paused.
+	time.Sleep(2 * tinyDuration)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&nRan))
+
+	stat.Cont()
+	<-done
+	assert.Equal(t, int32(2), nRan)
+}
+
+func TestWorkers(t *testing.T) {
+	var running, max int32
+	workers := NewWorkers(3)
+	pb := PhaseOf(succeed)
+	for i := 0; i < 4; i += 1 {
+		pb = pb.And(SequenceOf(PhaseOf(succeed).
+			AndJust(counting(&running, &max)).
+			AndJust(counting(&running, &max))))
+	}
+
+	stat := pb.End().RunAllWith(status.New(), RunOptions{Workers: workers})
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, int32(3), max)
+}
+
+func TestWorkersUnlimited(t *testing.T) {
+	var running, max int32
+	pb := PhaseOf(succeed)
+	for i := 0; i < 3; i += 1 {
+		pb = pb.AndJust(counting(&running, &max))
+	}
+
+	stat := pb.End().RunAllWith(status.New(), RunOptions{Workers: NewWorkers(0)})
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, int32(3), max)
+}
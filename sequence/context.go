@@ -0,0 +1,23 @@
+package sequence
+
+import (
+	"context"
+
+	"github.com/nedp/command/status"
+)
+
+type statusKey struct{}
+
+// Returns
+// a copy of `ctx` which carries `stat`, the status of the run.
+func withStatus(ctx context.Context, stat status.Interface) context.Context {
+	return context.WithValue(ctx, statusKey{}, stat)
+}
+
+// Returns
+// the status carried by `ctx`;
+// whether there was one.
+func statusFrom(ctx context.Context) (status.Interface, bool) {
+	stat, ok := ctx.Value(statusKey{}).(status.Interface)
+	return stat, ok
+}
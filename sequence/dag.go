@@ -0,0 +1,291 @@
+package sequence
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nedp/command/status"
+)
+
+// CycleError is returned by DAGBuilder.Build when the dependencies
+// of the graph's nodes form a cycle.
+type CycleError struct {
+	// The names of the nodes in the cycle, starting and ending
+	// with the same node.
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Cycle, " -> ")
+}
+
+// DependencyError is returned by DAGBuilder.Build when a node depends
+// on a node which wasn't added, or when two nodes have the same name.
+type DependencyError struct {
+	Node string
+
+	// The name of the missing dependency, or "" if the node's name
+	// is a duplicate.
+	Dependency string
+}
+
+func (e *DependencyError) Error() string {
+	if e.Dependency == "" {
+		return fmt.Sprintf("duplicate node %q", e.Node)
+	}
+	return fmt.Sprintf("node %q depends on unknown node %q", e.Node, e.Dependency)
+}
+
+// A builder for a graph of sequences, each of which starts as soon
+// as the sequences it depends on have succeeded, rather than waiting
+// for a whole phase like those of a sequence.
+//
+// The graph is run as a Sequence, so it may be run by anything which
+// runs a RunAller. Each node is identified in paths by its name.
+// A node whose dependencies don't all succeed is skipped.
+type DAGBuilder struct {
+	name string
+	nodes []dagNode
+}
+
+// A node of a DAGBuilder.
+type dagNode struct {
+	seq sequence
+	deps []string
+}
+
+// Starts building an empty graph.
+//
+// Returns
+// a builder for the graph.
+func NewDAG() DAGBuilder {
+	return DAGBuilder{}
+}
+
+// Adds a node named `name`, which runs the sequence built by `sb`
+// once each of the nodes named in `deps` has succeeded.
+//
+// Returns
+// a copy of the reciever, but with the node added.
+func (db DAGBuilder) Add(name string, sb SequenceBuilder, deps ...string) DAGBuilder {
+	seq := sb.finish()
+	seq.name = name
+	db.nodes = append(db.nodes[:len(db.nodes):len(db.nodes)], dagNode{seq, deps})
+	return db
+}
+
+// Adds a node named `name`, which runs `fn` once each of the nodes
+// named in `deps` has succeeded.
+//
+// Returns
+// a copy of the reciever, but with the node added.
+func (db DAGBuilder) AddJust(name string, fn func() error, deps ...string) DAGBuilder {
+	return db.Add(name, FirstJust(fn), deps...)
+}
+
+// Like AddJust, but `fn` is passed a context which is cancelled
+// when the status records a failure.
+//
+// Returns
+// a copy of the reciever, but with the node added.
+func (db DAGBuilder) AddJustCtx(name string, fn func(context.Context) error, deps ...string) DAGBuilder {
+	return db.Add(name, FirstJustCtx(fn), deps...)
+}
+
+// Like AddJust, but with a unit.
+//
+// Returns
+// a copy of the reciever, but with the node added.
+func (db DAGBuilder) AddJustUnit(name string, fn Unit, deps ...string) DAGBuilder {
+	return db.Add(name, FirstJustUnit(fn), deps...)
+}
+
+// Names the graph, which prefixes the paths of its nodes.
+//
+// Returns
+// a copy of the reciever with the name set.
+func (db DAGBuilder) Name(name string) DAGBuilder {
+	db.name = name
+	return db
+}
+
+// Checks the graph, and finishes building it.
+//
+// Returns
+// a runnable `Sequence` containing the graph;
+// a `*DependencyError` if a dependency is unknown or a name is
+// duplicated, or a `*CycleError` if the dependencies form a cycle.
+func (db DAGBuilder) Build() (Sequence, error) {
+	d, err := db.finish()
+	if err != nil {
+		return Sequence{}, err
+	}
+	seq := sequence{phases: []runAller{d}}
+	return Sequence{make(chan bool, 1), seq, new(outlet), new(progress)}, nil
+}
+
+// Returns
+// the graph, with its nodes ordered so that each follows its
+// dependencies;
+// an error if the graph is invalid. See `Build`.
+func (db DAGBuilder) finish() (dag, error) {
+	index := make(map[string]int, len(db.nodes))
+	for i, dn := range db.nodes {
+		if _, ok := index[dn.seq.name]; ok {
+			return dag{}, &DependencyError{Node: dn.seq.name}
+		}
+		index[dn.seq.name] = i
+	}
+	for _, dn := range db.nodes {
+		for _, dep := range dn.deps {
+			if _, ok := index[dep]; !ok {
+				return dag{}, &DependencyError{dn.seq.name, dep}
+			}
+		}
+	}
+
+	// Order the nodes depth first, in the order they were added.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(db.nodes))
+	order := make(map[int]int, len(db.nodes))
+	d := dag{name: db.name}
+	var stack []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		dn := db.nodes[i]
+		switch marks[i] {
+		case visited:
+			return nil
+		case visiting:
+			for j, name := range stack {
+				if name == dn.seq.name {
+					return &CycleError{append(stack[j:len(stack):len(stack)], name)}
+				}
+			}
+		}
+		marks[i] = visiting
+		stack = append(stack, dn.seq.name)
+		for _, dep := range dn.deps {
+			if err := visit(index[dep]); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		marks[i] = visited
+		order[i] = len(d.nodes)
+		d.nodes = append(d.nodes, dn.seq)
+		return nil
+	}
+	for i := range db.nodes {
+		if err := visit(i); err != nil {
+			return dag{}, err
+		}
+	}
+
+	d.deps = make([][]int, len(d.nodes))
+	for i, dn := range db.nodes {
+		for _, dep := range dn.deps {
+			d.deps[order[i]] = append(d.deps[order[i]], order[index[dep]])
+		}
+	}
+	return d, nil
+}
+
+// A graph of sequences, run as an element of a sequence.
+type dag struct {
+	name string
+
+	// The sequences of the graph, each following its dependencies.
+	nodes []sequence
+
+	// The indices of the dependencies of each node.
+	deps [][]int
+}
+
+func (d dag) unitName() string {
+	return d.name
+}
+
+func (d dag) plan(path string) *node {
+	n := &node{kind: KindDAG, name: d.name, path: path}
+	for i, seq := range d.nodes {
+		n.children = append(n.children, planOf(seq, childPath(path, i, seq.name)))
+	}
+	for i, deps := range d.deps {
+		for _, j := range deps {
+			n.children[i].deps = append(n.children[i].deps, n.children[j].path)
+		}
+	}
+	return n
+}
+
+// Runs each node of the graph once its dependencies have succeeded,
+// and waits for them all to finish.
+//
+// The nodes are compensated one at a time, in the reverse of the
+// topological order found by Build, so that each node is compensated
+// before its dependencies. This isn't necessarily the reverse of the
+// order in which they were added to the builder, or finished.
+func (d dag) runAll(ctx context.Context, stat status.Interface) status.Interface {
+	n := nodeFrom(ctx)
+	// Wait for previous computations to end before starting new ones.
+	if !stat.ReadyRLock() {
+		n.skip()
+		return stat
+	}
+	n.begin()
+
+	children := make([]*node, len(d.nodes))
+	done := make([]chan struct{}, len(d.nodes))
+	for i, seq := range d.nodes {
+		children[i] = n.child(i, seq)
+		done[i] = make(chan struct{})
+	}
+	s := storeFrom(ctx)
+	stat.Add(len(d.nodes))
+	for i, seq := range d.nodes {
+		s.enter()
+		go func(ctx context.Context, boundCopy status.Interface, i int, seq sequence) {
+			// Mark this node as done, even if it panics,
+			// once its failure has been recorded.
+			defer stat.Done()
+			defer close(done[i])
+			defer s.leave()
+			defer recoverInto(boundCopy)
+
+			var ok bool
+			s.idle(func() {
+				ok = d.succeeded(i, children, done)
+			})
+			if !ok {
+				nodeFrom(ctx).skip()
+				return
+			}
+			seq.runAll(ctx, boundCopy)
+		}(withNode(ctx, children[i]), stat.BoundCopy(), i, seq)
+	}
+	stat.RUnlock()
+
+	s.idle(stat.Wait)
+	n.finish(ctx, false)
+	return stat
+}
+
+// Waits for the dependencies of the `i`th node to finish.
+//
+// Returns
+// whether they all succeeded.
+func (d dag) succeeded(i int, children []*node, done []chan struct{}) bool {
+	for _, j := range d.deps[i] {
+		<-done[j]
+		if state, _ := children[j].outcome(); state != Succeeded {
+			return false
+		}
+	}
+	return true
+}
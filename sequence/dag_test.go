@@ -0,0 +1,107 @@
+package sequence
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+// Returns
+// a function which appends `name` to `order`, after sleeping for `d`.
+func ordered(mu *sync.Mutex, order *[]string, name string, d time.Duration) func() error {
+	return func() error {
+		time.Sleep(d)
+		mu.Lock()
+		defer mu.Unlock()
+		*order = append(*order, name)
+		return nil
+	}
+}
+
+func TestDAG(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	seq, err := NewDAG().
+		AddJust("deploy", ordered(&mu, &order, "deploy", 0), "build", "test").
+		AddJust("build", ordered(&mu, &order, "build", 0)).
+		AddJust("test", ordered(&mu, &order, "test", 2*milliDuration)).
+		AddJust("docs", ordered(&mu, &order, "docs", 0), "build").
+		Name("release").
+		Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	// docs doesn't wait for test, which is slower.
+	assert.Equal(t, []string{"build", "docs", "test", "deploy"}, order)
+	assert.Equal(t, map[string]State{
+		"": Succeeded, "release": Succeeded,
+		"release/build": Succeeded, "release/build/1": Succeeded,
+		"release/test": Succeeded, "release/test/1": Succeeded,
+		"release/docs": Succeeded, "release/docs/1": Succeeded,
+		"release/deploy": Succeeded, "release/deploy/1": Succeeded,
+	}, states(seq.Snapshot()))
+	assert.Equal(t, KindDAG, seq.Snapshot().Children[0].Kind)
+}
+
+func TestDAGFailureSkipsDependents(t *testing.T) {
+	failure := errors.New("A failure")
+	ran := false
+	seq, err := NewDAG().
+		AddJust("a", func() error {
+			return failure
+		}).
+		AddJust("b", func() error {
+			ran = true
+			return nil
+		}, "a").
+		Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stat := seq.RunAll(status.New())
+
+	assert.True(t, stat.HasFailed())
+	assert.Equal(t, &UnitError{"1/a/1", failure}, stat.Err())
+	assert.False(t, ran)
+	assert.Equal(t, map[string]State{
+		"": Failed, "1": Failed,
+		"1/a": Failed, "1/a/1": Failed,
+		"1/b": Skipped, "1/b/1": Skipped,
+	}, states(seq.Snapshot()))
+}
+
+func TestDAGCycle(t *testing.T) {
+	_, err := NewDAG().
+		AddJust("a", succeed).
+		AddJust("b", succeed, "a", "d").
+		AddJust("c", succeed, "b").
+		AddJust("d", succeed, "c").
+		Build()
+
+	assert.Equal(t, &CycleError{[]string{"b", "d", "c", "b"}}, err)
+	assert.EqualError(t, err, "dependency cycle: b -> d -> c -> b")
+}
+
+func TestDAGSelfDependency(t *testing.T) {
+	_, err := NewDAG().AddJust("a", succeed, "a").Build()
+
+	assert.Equal(t, &CycleError{[]string{"a", "a"}}, err)
+}
+
+func TestDAGInvalidNodes(t *testing.T) {
+	_, err := NewDAG().AddJust("a", succeed, "b").Build()
+	assert.Equal(t, &DependencyError{"a", "b"}, err)
+
+	_, err = NewDAG().AddJust("a", succeed).AddJust("a", succeed).Build()
+	assert.Equal(t, &DependencyError{Node: "a"}, err)
+}
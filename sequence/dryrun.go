@@ -0,0 +1,57 @@
+package sequence
+
+import (
+	"context"
+
+	"github.com/nedp/command/status"
+)
+
+type dryRunKey struct{}
+
+// Returns
+// a copy of `ctx` which marks the run as a dry run,
+// or `ctx` if `dry` is false.
+func withDryRun(ctx context.Context, dry bool) context.Context {
+	if !dry {
+		return ctx
+	}
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// Returns
+// whether the run whose context is `ctx` is a dry run.
+func isDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+// Calls the main function, retrying it according to the phase's
+// retry policy; or in a dry run, describes it instead.
+//
+// Returns
+// the error of the main function, or of its last attempt.
+func (ph phase) runMain(ctx context.Context, stat status.Interface) error {
+	if !isDryRun(ctx) {
+		return ph.retry.call(ctx, stat, ph.call)
+	}
+	text := "would run"
+	if ph.description != nil {
+		text = ph.description(ctx)
+	}
+	emit(ctx, Record{Severity: SeverityInfo, Text: text})
+	return nil
+}
+
+// Sets `fn` to describe what the phase's main function does,
+// in the plan listed by a dry run.
+//
+// In a dry run, `fn` is called in place of the main function,
+// and its result is sent to the output channel. Without a
+// description, the main function is listed as "would run".
+//
+// Returns
+// a copy of the reciever, but with the description set.
+func (pb PhaseBuilder) Describe(fn func(ctx context.Context) string) PhaseBuilder {
+	pb.description = fn
+	return pb
+}
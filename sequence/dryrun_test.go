@@ -0,0 +1,105 @@
+package sequence
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+// Returns
+// a main function which records that it was called.
+func sideEffect(called *bool) func() error {
+	return func() error {
+		*called = true
+		return nil
+	}
+}
+
+// Returns
+// a description function which returns `text`.
+func described(text string) func(context.Context) string {
+	return func(context.Context) string {
+		return text
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	called := false
+	hookCalled := false
+	seq := SequenceOf(PhaseOf(sideEffect(&called)).Describe(described("build")).
+		Compensate(sideEffect(&called)).
+		Before(func(context.Context) error {
+			hookCalled = true
+			return nil
+		})).
+		Then(PhaseOf(sideEffect(&called)).Name("deploy").
+			And(FirstJust(sideEffect(&called))).
+			And(SequenceOf(PhaseOf(sideEffect(&called)).Describe(described("deploy us"))))).
+		ThenJust(sideEffect(&called)).
+		End()
+	out := collect(seq)
+
+	stat := seq.RunAllWith(status.New(), RunOptions{DryRun: true})
+
+	assert.False(t, stat.HasFailed())
+	assert.False(t, called)
+	assert.False(t, hookCalled)
+	lines := <-out
+	// The sub-sequences of a phase are listed concurrently.
+	if assert.Len(t, lines, 5) {
+		assert.Equal(t, "1: build", lines[0])
+		concurrent := append([]string(nil), lines[1:4]...)
+		sort.Strings(concurrent)
+		assert.Equal(t, []string{
+			"deploy/1/1: would run", "deploy/2/1: deploy us", "deploy: would run",
+		}, concurrent)
+		assert.Equal(t, "3: would run", lines[4])
+	}
+	assert.Equal(t, Succeeded, seq.Snapshot().State)
+}
+
+func TestDryRunControlFlow(t *testing.T) {
+	called := false
+	never := func(context.Context) bool { return false }
+	seq := FirstJust(sideEffect(&called)).
+		ThenIf(never, PhaseOf(sideEffect(&called)).Name("then")).
+		Else(PhaseOf(sideEffect(&called)).Name("else")).
+		ThenRepeat(3, PhaseOf(sideEffect(&called)).Name("loop")).
+		Then(ForEach(func(context.Context) ([]int, error) {
+			called = true
+			return []int{1}, nil
+		}, func(int) SequenceBuilder {
+			return FirstJust(sideEffect(&called))
+		}).Name("each")).
+		End()
+	out := collect(seq)
+
+	seq.RunAllWith(status.New(), RunOptions{DryRun: true})
+
+	assert.False(t, called)
+	assert.Equal(t, []string{
+		"1: would run",
+		"then: would run",
+		"else: would run",
+		"loop: would run",
+		"each: would run a sub-sequence for each item",
+	}, <-out)
+	assert.Equal(t, 1, seq.Snapshot().Children[3].Iterations)
+}
+
+func TestDryRunThenRun(t *testing.T) {
+	called := false
+	seq := FirstJust(sideEffect(&called)).End()
+	out := collect(seq)
+
+	seq.RunAllWith(status.New(), RunOptions{DryRun: true})
+	assert.False(t, called)
+	assert.Equal(t, []string{"1: would run"}, <-out)
+
+	seq.RunAll(status.New())
+	assert.True(t, called)
+}
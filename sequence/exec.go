@@ -0,0 +1,130 @@
+//go:build unix
+
+package sequence
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// ExitError is returned by a function made by Exec when its
+// process exits unsuccessfully.
+type ExitError struct {
+	Name string
+
+	// The exit code, or -1 if the process was killed by a signal.
+	Code int
+
+	Err *exec.ExitError
+}
+
+func (e *ExitError) Error() string {
+	if e.Code < 0 {
+		return fmt.Sprintf("%s: %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s: exited with code %d", e.Name, e.Code)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Exec makes a function which runs the program `name` with `args`
+// in its own process group, for use as a unit of computation.
+//
+// Each line the process writes to stdout or stderr is sent to the
+// sequence's output channel, as a record of the matching stream.
+// When the function's context is cancelled, such as when the
+// command is stopped, the process group is killed.
+// When the sequence's status is paused or continued, the process
+// group is sent SIGSTOP or SIGCONT.
+//
+// Returns
+// a function which runs the process each time it is called,
+// returning an `*ExitError` if it exits unsuccessfully.
+func Exec(name string, args ...string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		cmd.Cancel = func() error {
+			return signalGroup(cmd, syscall.SIGKILL)
+		}
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		exited := make(chan struct{})
+		defer close(exited)
+		if stat, ok := statusFrom(ctx); ok {
+			go forwardPauses(stat.Paused, stat.Unpaused, cmd, exited)
+		}
+
+		// The pipes must be drained before waiting for the process.
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go emitLines(ctx, stdout, StreamStdout, &wg)
+		go emitLines(ctx, stderr, StreamStderr, &wg)
+		wg.Wait()
+
+		err = cmd.Wait()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			return &ExitError{name, exitErr.ExitCode(), exitErr}
+		}
+		return err
+	}
+}
+
+// Sends SIGSTOP to the process group of `cmd` each time the status
+// is paused, and SIGCONT each time it is continued, until `exited`
+// is closed.
+func forwardPauses(paused, unpaused func() <-chan struct{},
+	cmd *exec.Cmd, exited <-chan struct{}) {
+	for {
+		select {
+		case <-paused():
+		case <-exited:
+			return
+		}
+		_ = signalGroup(cmd, syscall.SIGSTOP)
+
+		select {
+		case <-unpaused():
+		case <-exited:
+			return
+		}
+		_ = signalGroup(cmd, syscall.SIGCONT)
+	}
+}
+
+// Sends `sig` to the process group of the started `cmd`.
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+// Sends each line read from `r` to the output channel as a record
+// of the stream `s`, calling wg.Done once `r` is exhausted.
+func emitLines(ctx context.Context, r io.Reader, s Stream, wg *sync.WaitGroup) {
+	defer wg.Done()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		emit(ctx, Record{Stream: s, Text: scanner.Text()})
+	}
+	// Drain the rest so that the process isn't blocked writing.
+	_, _ = io.Copy(io.Discard, r)
+}
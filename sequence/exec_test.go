@@ -0,0 +1,71 @@
+//go:build unix
+
+package sequence
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+func TestExec(t *testing.T) {
+	seq := PhaseOfCtx(
+		Exec("sh", "-c", "echo to stdout; echo to stderr >&2; exit 3"),
+	).End()
+
+	out := collect(seq)
+	stat := seq.RunAll(status.New())
+
+	var exitErr *ExitError
+	if assert.ErrorAs(t, stat.Err(), &exitErr) {
+		assert.Equal(t, 3, exitErr.Code)
+	}
+	lines := <-out
+	sort.Strings(lines)
+	assert.Equal(t, []string{"1: to stderr", "1: to stdout"}, lines)
+}
+
+func TestExecStopped(t *testing.T) {
+	stat := status.New()
+	seq := PhaseOfCtx(Exec("sleep", "10")).End()
+
+	go func() {
+		time.Sleep(milliDuration)
+		stat.Fail()
+	}()
+	start := time.Now()
+	seq.RunAll(stat)
+
+	assert.WithinDuration(t, start, time.Now(), tinyDuration,
+		"The process wasn't killed when stopped")
+}
+
+func TestExecPaused(t *testing.T) {
+	stat := status.New()
+	seq := PhaseOfCtx(Exec("sh", "-c", "sleep 0.1; echo done")).End()
+	out := seq.OutputChannel()
+
+	go seq.RunAll(stat)
+	time.Sleep(milliDuration)
+	stat.Pause()
+
+	// The process is stopped, so it can't finish while paused.
+	select {
+	case <-out:
+		t.Error("The process continued while paused")
+	case <-time.After(tinyDuration):
+		// Okay
+	}
+
+	stat.Cont()
+	select {
+	case s := <-out:
+		assert.Equal(t, "1: done", s)
+	case <-time.After(tinyDuration):
+		t.Error("The process wasn't continued")
+	}
+}
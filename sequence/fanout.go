@@ -0,0 +1,72 @@
+package sequence
+
+import (
+	"context"
+
+	"github.com/nedp/command/status"
+)
+
+// ForEach starts building a phase which, when it runs, calls `items`
+// and runs a sub-sequence built by `build` for each of the items.
+//
+// The sub-sequences run concurrently, like those added with And,
+// after any which were added with And. `items` may use the Results
+// of earlier phases, and the sub-sequences may add to Results for
+// later phases. If `items` fails, the phase fails without running
+// any of them. In a dry run, `items` isn't called, so none of
+// them run.
+//
+// Returns
+// a phase builder for the phase, which has no main function.
+func ForEach[T any](items func(context.Context) ([]T, error),
+	build func(item T) SequenceBuilder) PhaseBuilder {
+	pb := PhaseOfSequences().Describe(func(context.Context) string {
+		return "would run a sub-sequence for each item"
+	})
+	pb.expand = func(ctx context.Context) ([]runAller, error) {
+		ts, err := items(ctx)
+		if err != nil {
+			return nil, err
+		}
+		seqs := make([]runAller, len(ts))
+		for i, t := range ts {
+			seqs[i] = build(t).finish()
+		}
+		return seqs, nil
+	}
+	return pb
+}
+
+// Adds the sub-sequences produced by the phase's expansion, once the
+// status is ready.
+//
+// Returns
+// the expanded phase;
+// whether it should be run, which is false if the status failed
+// or the expansion failed.
+func (ph phase) expanded(ctx context.Context, stat status.Interface) (phase, bool) {
+	n := nodeFrom(ctx)
+	// Don't expand while paused, or after a failure.
+	if !stat.ReadyRLock() {
+		n.skip()
+		return ph, false
+	}
+	stat.RUnlock()
+
+	var seqs []runAller
+	err := protect(func(ctx context.Context) (err error) {
+		seqs, err = ph.expand(ctx)
+		return err
+	})(ctx)
+	if err != nil {
+		err = attribute(ctx, err)
+		n.begin()
+		n.fail(err, false)
+		n.finish(ctx, false)
+		_ = stat.FailWith(err) // Don't care if a failure already occured.
+		return ph, false
+	}
+
+	ph.sequences = append(ph.sequences[:len(ph.sequences):len(ph.sequences)], seqs...)
+	return ph, true
+}
@@ -0,0 +1,144 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+func TestForEach(t *testing.T) {
+	squares := NewResults[int]()
+	var sum int
+	seq := FirstJust(succeed).
+		Then(ForEach(func(context.Context) ([]int, error) {
+			return []int{1, 2, 3}, nil
+		}, func(i int) SequenceBuilder {
+			return FirstJustCtx(func(ctx context.Context) error {
+				squares.Add(ctx, i*i)
+				return nil
+			})
+		}).Name("squares")).
+		ThenJustCtx(func(ctx context.Context) error {
+			for _, sq := range squares.All(ctx) {
+				sum += sq
+			}
+			return nil
+		}).
+		End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, 14, sum)
+	assert.Equal(t, map[string]State{
+		"": Succeeded, "1": Succeeded, "squares": Succeeded,
+		"squares/1": Succeeded, "squares/1/1": Succeeded,
+		"squares/2": Succeeded, "squares/2/1": Succeeded,
+		"squares/3": Succeeded, "squares/3/1": Succeeded,
+		"3": Succeeded,
+	}, states(seq.Snapshot()))
+}
+
+func TestForEachUsesEarlierResults(t *testing.T) {
+	names := NewResults[string]()
+	seq := FirstJustCtx(func(ctx context.Context) error {
+		names.Add(ctx, "a")
+		names.Add(ctx, "b")
+		return nil
+	}).
+		Then(ForEach(func(ctx context.Context) ([]string, error) {
+			return names.All(ctx), nil
+		}, func(name string) SequenceBuilder {
+			return FirstJustUnit(func(ctx context.Context, out *Emitter) error {
+				out.Emit(name)
+				return nil
+			})
+		})).
+		End()
+	out := collect(seq)
+
+	stat := seq.RunAll(status.New())
+
+	got := <-out
+	sort.Strings(got)
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, []string{"2/1/1: a", "2/2/1: b"}, got)
+}
+
+func TestForEachItemsFailure(t *testing.T) {
+	failure := errors.New("A failure")
+	built := false
+	seq := FirstJust(succeed).
+		Then(ForEach(func(context.Context) ([]int, error) {
+			return nil, failure
+		}, func(int) SequenceBuilder {
+			built = true
+			return FirstJust(succeed)
+		})).
+		ThenJust(succeed).
+		End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.True(t, stat.HasFailed())
+	assert.Equal(t, &UnitError{"2", failure}, stat.Err())
+	assert.False(t, built)
+	assert.Equal(t, map[string]State{
+		"": Failed, "1": Succeeded, "2": Failed, "3": Skipped,
+	}, states(seq.Snapshot()))
+}
+
+func TestForEachNoItems(t *testing.T) {
+	seq := ForEach(func(context.Context) ([]int, error) {
+		return nil, nil
+	}, func(int) SequenceBuilder {
+		return FirstJust(succeed)
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, Succeeded, seq.Snapshot().Children[0].State)
+}
+
+func TestResultsPerRun(t *testing.T) {
+	results := NewResults[int]()
+	var lens []int
+	seq := FirstJustCtx(func(ctx context.Context) error {
+		results.Add(ctx, 1)
+		lens = append(lens, len(results.All(ctx)))
+		return nil
+	}).End()
+
+	seq.RunAll(status.New())
+	seq.RunAll(status.New())
+
+	assert.Equal(t, []int{1, 1}, lens)
+}
+
+func TestForEachReportsNoUnit(t *testing.T) {
+	seq := SequenceOf(ForEach(func(context.Context) ([]int, error) {
+		return []int{1}, nil
+	}, func(int) SequenceBuilder {
+		return FirstJust(succeed)
+	})).Then(PhaseOfSequences(FirstJust(succeed))).End()
+	stat := status.New()
+	events, unsubscribe := stat.Subscribe(64)
+
+	seq.RunAll(stat)
+	unsubscribe()
+
+	var units []string
+	for e := range events {
+		if e.Kind == status.EventUnitStarted {
+			units = append(units, e.Path)
+		}
+	}
+	sort.Strings(units)
+	assert.Equal(t, []string{"1/1/1", "2/1/1"}, units)
+}
@@ -0,0 +1,313 @@
+package sequence
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Options for rendering a Snapshot as a graph.
+type GraphOptions struct {
+	// Whether to annotate each element with its state, and colour
+	// it by its state. Useful with the snapshot of a running or
+	// finished Sequence or Command.
+	States bool
+}
+
+// Renders the tree described by `s` as a Graphviz DOT digraph.
+//
+// Each sequence, and each phase with sub-sequences, is drawn as a
+// cluster. Solid edges join phases in the order they run; dashed
+// edges lead from a phase to the sub-sequences it runs concurrently,
+// and from the nodes of a DAG to the nodes which depend on them.
+//
+// Returns
+// the DOT source.
+func DOT(s Snapshot, opts GraphOptions) string {
+	g := graph{opts: opts}
+	g.WriteString("digraph sequence {\n")
+	g.WriteString("\tcompound=true;\n")
+	g.WriteString("\tnode [shape=box, style=\"rounded,filled\", fillcolor=white];\n")
+	g.dot(s, "\t")
+	g.WriteString("}\n")
+	return g.String()
+}
+
+// Renders the tree described by `s` as a Mermaid flowchart.
+// See `DOT`.
+//
+// Returns
+// the Mermaid source.
+func Mermaid(s Snapshot, opts GraphOptions) string {
+	g := graph{opts: opts}
+	g.WriteString("flowchart TD\n")
+	g.mermaid(s, "\t")
+	if opts.States {
+		for st := Pending; st <= Compensated; st += 1 {
+			fmt.Fprintf(&g, "\tclassDef %s fill:%s;\n", st, stateColour(st))
+		}
+		for _, c := range g.classes {
+			fmt.Fprintf(&g, "\tclass %s %s;\n", c.id, c.state)
+		}
+	}
+	return g.String()
+}
+
+// The colours of the elements in each state.
+func stateColour(st State) string {
+	switch st {
+	case Running:
+		return "lightblue"
+	case Succeeded:
+		return "palegreen"
+	case Failed:
+		return "salmon"
+	case Skipped:
+		return "lightgrey"
+	case Compensated:
+		return "khaki"
+	}
+	return "white"
+}
+
+// A graph being rendered.
+type graph struct {
+	strings.Builder
+	opts GraphOptions
+
+	// The number of IDs used so far.
+	nIDs int
+
+	// The ends of the elements rendered so far, by path.
+	ends map[string]ends
+
+	// The states of the Mermaid nodes and subgraphs rendered so far.
+	classes []class
+}
+
+// The IDs at which the edges leading to and from an element end.
+type ends struct {
+	first string
+	last string
+
+	// The ID of the element's cluster, or "" if it isn't one.
+	cluster string
+}
+
+type class struct {
+	id string
+	state State
+}
+
+// Returns
+// a new, unique ID.
+func (g *graph) id() string {
+	g.nIDs += 1
+	return fmt.Sprintf("n%d", g.nIDs)
+}
+
+// Records the ends of the element at `path`.
+func (g *graph) record(path string, e ends) ends {
+	if g.ends == nil {
+		g.ends = make(map[string]ends)
+	}
+	g.ends[path] = e
+	return e
+}
+
+// Returns
+// the lines of the label of the element described by `s`.
+func (g *graph) label(s Snapshot) []string {
+	lines := []string{s.Kind.String()}
+	if s.Path != "" {
+		lines[0] += " " + s.Path
+	}
+	if g.opts.States {
+		lines = append(lines, s.State.String())
+		if s.Iterations > 0 {
+			lines[1] += fmt.Sprintf(" (%d iterations)", s.Iterations)
+		}
+		if s.Err != nil {
+			lines = append(lines, s.Err.Error())
+		}
+	}
+	return lines
+}
+
+// Renders the element described by `s`, and its children, as DOT.
+//
+// Returns
+// the ends of the element.
+func (g *graph) dot(s Snapshot, indent string) ends {
+	label := dotQuote(strings.Join(g.label(s), "\n"))
+	if s.Kind == KindPhase && len(s.Children) == 0 {
+		id := g.id()
+		fmt.Fprintf(g, "%s%s [label=%s%s];\n", indent, id, label, g.dotFill(s))
+		return g.record(s.Path, ends{id, id, ""})
+	}
+
+	cluster := "cluster_" + g.id()
+	fmt.Fprintf(g, "%ssubgraph %s {\n", indent, cluster)
+	inner := indent + "\t"
+	fmt.Fprintf(g, "%slabel=%s;\n", inner, label)
+	if g.opts.States {
+		fmt.Fprintf(g, "%sstyle=filled;\n%sfillcolor=%s;\n", inner, inner, dotQuote(stateColour(s.State)))
+	}
+
+	var e ends
+	switch s.Kind {
+	case KindPhase:
+		// The phase's own node stands for its main function.
+		id := g.id()
+		fmt.Fprintf(g, "%s%s [label=\"main\"%s];\n", inner, id, g.dotFill(s))
+		e = ends{id, id, cluster}
+		for _, c := range s.Children {
+			ce := g.dot(c, inner)
+			fmt.Fprintf(g, "%s%s -> %s [style=dashed%s];\n", inner, id, ce.first, dotHead(ce))
+		}
+	case KindDAG:
+		e.cluster = cluster
+		for i, c := range s.Children {
+			ce := g.dot(c, inner)
+			if i == 0 {
+				e.first = ce.first
+			}
+			e.last = ce.last
+		}
+		for _, c := range s.Children {
+			to := g.ends[c.Path]
+			for _, dep := range c.Deps {
+				from := g.ends[dep]
+				fmt.Fprintf(g, "%s%s -> %s [style=dashed%s%s];\n",
+					inner, from.last, to.first, dotTail(from), dotHead(to))
+			}
+		}
+	default:
+		e.cluster = cluster
+		var prev ends
+		for i, c := range s.Children {
+			ce := g.dot(c, inner)
+			if i == 0 {
+				e.first = ce.first
+			} else {
+				fmt.Fprintf(g, "%s%s -> %s%s;\n", inner, prev.last, ce.first,
+					dotAttrs(dotTail(prev)+dotHead(ce)))
+			}
+			prev = ce
+		}
+		e.last = prev.last
+		if e.first == "" {
+			// Give an empty cluster a node, so that it is drawn.
+			id := g.id()
+			fmt.Fprintf(g, "%s%s [label=\"empty\", style=invis];\n", inner, id)
+			e.first, e.last = id, id
+		}
+	}
+	fmt.Fprintf(g, "%s}\n", indent)
+	return g.record(s.Path, e)
+}
+
+// Returns
+// the DOT attributes which colour the node of `s` by its state,
+// if states are rendered.
+func (g *graph) dotFill(s Snapshot) string {
+	if !g.opts.States {
+		return ""
+	}
+	return ", fillcolor=" + dotQuote(stateColour(s.State))
+}
+
+// Returns
+// the DOT attribute making an edge start at the cluster of `e`,
+// if it has one.
+func dotTail(e ends) string {
+	if e.cluster == "" {
+		return ""
+	}
+	return ", ltail=" + e.cluster
+}
+
+// Returns
+// the DOT attribute making an edge end at the cluster of `e`,
+// if it has one.
+func dotHead(e ends) string {
+	if e.cluster == "" {
+		return ""
+	}
+	return ", lhead=" + e.cluster
+}
+
+// Returns
+// the DOT attribute list of the attributes `attrs`, which each follow
+// ", ", or "" if there are none.
+func dotAttrs(attrs string) string {
+	if attrs == "" {
+		return ""
+	}
+	return " [" + strings.TrimPrefix(attrs, ", ") + "]"
+}
+
+// Returns
+// `s` as a quoted DOT string.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// Renders the element described by `s`, and its children, as a
+// Mermaid flowchart.
+//
+// Returns
+// the ends of the element. Mermaid edges may join subgraphs, so
+// the cluster is used as both ends if there is one.
+func (g *graph) mermaid(s Snapshot, indent string) ends {
+	label := mermaidQuote(strings.Join(g.label(s), "\n"))
+	if s.Kind == KindPhase && len(s.Children) == 0 {
+		id := g.id()
+		fmt.Fprintf(g, "%s%s[%s]\n", indent, id, label)
+		g.classes = append(g.classes, class{id, s.State})
+		return g.record(s.Path, ends{id, id, ""})
+	}
+
+	cluster := g.id()
+	fmt.Fprintf(g, "%ssubgraph %s [%s]\n", indent, cluster, label)
+	g.classes = append(g.classes, class{cluster, s.State})
+	inner := indent + "\t"
+	e := ends{cluster, cluster, cluster}
+	switch s.Kind {
+	case KindPhase:
+		id := g.id()
+		fmt.Fprintf(g, "%s%s[\"main\"]\n", inner, id)
+		g.classes = append(g.classes, class{id, s.State})
+		for _, c := range s.Children {
+			ce := g.mermaid(c, inner)
+			fmt.Fprintf(g, "%s%s -.-> %s\n", inner, id, ce.first)
+		}
+	case KindDAG:
+		for _, c := range s.Children {
+			g.mermaid(c, inner)
+		}
+		for _, c := range s.Children {
+			for _, dep := range c.Deps {
+				fmt.Fprintf(g, "%s%s -.-> %s\n", inner, g.ends[dep].last, g.ends[c.Path].first)
+			}
+		}
+	default:
+		var prev ends
+		for i, c := range s.Children {
+			ce := g.mermaid(c, inner)
+			if i > 0 {
+				fmt.Fprintf(g, "%s%s --> %s\n", inner, prev.last, ce.first)
+			}
+			prev = ce
+		}
+	}
+	fmt.Fprintf(g, "%send\n", indent)
+	return g.record(s.Path, e)
+}
+
+// Returns
+// `s` as a quoted Mermaid label.
+func mermaidQuote(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "\n", "<br/>")
+	return `"` + r.Replace(s) + `"`
+}
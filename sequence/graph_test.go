@@ -0,0 +1,108 @@
+package sequence
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+func TestDOT(t *testing.T) {
+	seq := FirstJust(succeed).
+		Then(PhaseOf(succeed).AndJust(succeed).Name("fork")).
+		Name("A").
+		End()
+
+	assert.Equal(t, `digraph sequence {
+	compound=true;
+	node [shape=box, style="rounded,filled", fillcolor=white];
+	subgraph cluster_n1 {
+		label="sequence A";
+		n2 [label="phase A/1"];
+		subgraph cluster_n3 {
+			label="phase A/fork";
+			n4 [label="main"];
+			subgraph cluster_n5 {
+				label="sequence A/fork/1";
+				n6 [label="phase A/fork/1/1"];
+			}
+			n4 -> n6 [style=dashed, lhead=cluster_n5];
+		}
+		n2 -> n4 [lhead=cluster_n3];
+	}
+}
+`, DOT(seq.Snapshot(), GraphOptions{}))
+}
+
+func TestMermaid(t *testing.T) {
+	seq := FirstJust(succeed).
+		Then(PhaseOf(succeed).AndJust(succeed).Name("fork")).
+		ThenJust(succeed).
+		End()
+
+	assert.Equal(t, `flowchart TD
+	subgraph n1 ["sequence"]
+		n2["phase 1"]
+		subgraph n3 ["phase fork"]
+			n4["main"]
+			subgraph n5 ["sequence fork/1"]
+				n6["phase fork/1/1"]
+			end
+			n4 -.-> n5
+		end
+		n2 --> n3
+		n7["phase 3"]
+		n3 --> n7
+	end
+`, Mermaid(seq.Snapshot(), GraphOptions{}))
+}
+
+func TestGraphStates(t *testing.T) {
+	failure := errors.New(`A "failure"`)
+	seq := FirstJust(succeed).
+		ThenRepeat(2, PhaseOf(succeed)).
+		ThenJust(func() error {
+			return failure
+		}).
+		ThenJust(succeed).
+		End()
+	seq.RunAll(status.New())
+	s := seq.Snapshot()
+
+	dot := DOT(s, GraphOptions{States: true})
+	assert.Contains(t, dot, `label="sequence\nfailed";`)
+	assert.Contains(t, dot, `n3 [label="phase 2\nsucceeded (2 iterations)", fillcolor="palegreen"];`)
+	assert.Contains(t, dot, `n4 [label="phase 3\nfailed\n3: A \"failure\"", fillcolor="salmon"];`)
+	assert.Contains(t, dot, `n5 [label="phase 4\nskipped", fillcolor="lightgrey"];`)
+
+	mermaid := Mermaid(s, GraphOptions{States: true})
+	assert.Contains(t, mermaid, `n4["phase 3<br/>failed<br/>3: A #quot;failure#quot;"]`)
+	assert.Contains(t, mermaid, "classDef failed fill:salmon;")
+	assert.Contains(t, mermaid, "class n4 failed;")
+	assert.Contains(t, mermaid, "class n5 skipped;")
+}
+
+func TestGraphDAG(t *testing.T) {
+	seq, err := NewDAG().
+		AddJust("a", succeed).
+		AddJust("b", succeed).
+		AddJust("c", succeed, "a", "b").
+		Name("D").
+		Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	s := seq.Snapshot()
+
+	dot := DOT(s, GraphOptions{})
+	assert.Contains(t, dot, `label="dag D";`)
+	assert.Contains(t, dot, "n4 -> n8 [style=dashed, ltail=cluster_n3, lhead=cluster_n7];")
+	assert.Contains(t, dot, "n6 -> n8 [style=dashed, ltail=cluster_n5, lhead=cluster_n7];")
+
+	mermaid := Mermaid(s, GraphOptions{})
+	assert.Equal(t, 2, strings.Count(mermaid, "-.-> n7"))
+	assert.Equal(t, []string{"D/a", "D/b"}, s.Children[0].Children[2].Deps)
+}
@@ -0,0 +1,126 @@
+package sequence
+
+import (
+	"context"
+
+	"github.com/nedp/command/status"
+)
+
+// Hooks are functions run around a phase or a sequence,
+// for behaviour which isn't part of its units of computation.
+// Any of them may be nil.
+//
+// Failures of hooks aren't tolerated or isolated by the failure
+// policy of the element they're run around.
+type Hooks struct {
+	// Run before the element starts. If it returns an error,
+	// the element fails without being run.
+	Before func(ctx context.Context) error
+
+	// Run after the element succeeds. If it returns an error,
+	// the element fails.
+	After func(ctx context.Context) error
+
+	// Run after the element fails, with the error which caused
+	// the failure.
+	OnError func(ctx context.Context, err error)
+
+	// Run once the element has finished, even if the status has
+	// failed. It is passed a context which isn't cancelled by
+	// the failure.
+	Finally func(ctx context.Context)
+}
+
+// HookError is recorded when a Before or After hook returns an error.
+type HookError struct {
+	// "before" or "after".
+	Hook string
+
+	// The path of the element the hook was run around.
+	Path string
+	Err error
+}
+
+func (e *HookError) Error() string {
+	if e.Path == "" {
+		return e.Hook + " hook failed: " + e.Err.Error()
+	}
+	return e.Hook + " hook of " + e.Path + " failed: " + e.Err.Error()
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// Whether none of the hooks are set.
+func (h Hooks) isZero() bool {
+	return h.Before == nil && h.After == nil && h.OnError == nil && h.Finally == nil
+}
+
+// Runs `run` between the hooks.
+//
+// Once the status is ready, Before is called; `run` is called only
+// if Before succeeds. Then After or OnError is called, depending on
+// whether the element succeeded or failed, and finally Finally.
+// Nothing is called if the status fails before the element starts.
+// In a dry run, only `run` is called.
+//
+// Returns
+// the status returned by `run`, or `stat` if it wasn't called.
+func (h Hooks) around(ctx context.Context, stat status.Interface,
+	run func(context.Context, status.Interface) status.Interface) status.Interface {
+	if h.isZero() || isDryRun(ctx) {
+		return run(ctx, stat)
+	}
+	n := nodeFrom(ctx)
+
+	// Don't start the hooks while paused, or after a failure.
+	if !stat.ReadyRLock() {
+		n.skip()
+		return stat
+	}
+	stat.RUnlock()
+
+	if h.Finally != nil {
+		defer h.Finally(context.WithoutCancel(ctx))
+	}
+
+	if h.Before != nil {
+		if err := h.Before(ctx); err != nil {
+			err = &HookError{"before", n.path, err}
+			n.begin()
+			n.fail(err, false)
+			n.finish(ctx, false)
+			_ = stat.FailWith(err) // Don't care if a failure already occured.
+			h.onError(ctx, err)
+			return stat
+		}
+	}
+
+	stat = run(ctx, stat)
+
+	state, err := n.outcome()
+	if state == Failed {
+		if err == nil {
+			err = stat.Err()
+		}
+		h.onError(ctx, err)
+		return stat
+	}
+	if state == Succeeded && h.After != nil {
+		if err := h.After(ctx); err != nil {
+			err = &HookError{"after", n.path, err}
+			n.override(err)
+			_ = stat.FailWith(err) // Don't care if a failure already occured.
+			h.onError(ctx, err)
+		}
+	}
+	return stat
+}
+
+// Calls OnError with `err`, if it is set.
+func (h Hooks) onError(ctx context.Context, err error) {
+	if h.OnError != nil {
+		h.OnError(ctx, err)
+	}
+}
@@ -0,0 +1,122 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+// A log of the hooks which were called.
+type hookLog struct {
+	sync.Mutex
+	calls []string
+}
+
+func (l *hookLog) add(call string) {
+	l.Lock()
+	defer l.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+// Returns
+// hooks which log their calls, prefixed with `name`;
+// the Before and After hooks return `beforeErr` and `afterErr`.
+func (l *hookLog) hooks(name string, beforeErr, afterErr error) Hooks {
+	return Hooks{
+		Before: func(context.Context) error {
+			l.add(name + " before")
+			return beforeErr
+		},
+		After: func(context.Context) error {
+			l.add(name + " after")
+			return afterErr
+		},
+		OnError: func(_ context.Context, err error) {
+			l.add(name + " on error: " + err.Error())
+		},
+		Finally: func(ctx context.Context) {
+			if ctx.Err() != nil {
+				l.add(name + " finally, cancelled")
+				return
+			}
+			l.add(name + " finally")
+		},
+	}
+}
+
+// Returns
+// a phase builder for `fn` with the hooks `h`.
+func hooked(fn func() error, h Hooks) PhaseBuilder {
+	return PhaseOf(fn).Before(h.Before).After(h.After).OnError(h.OnError).Finally(h.Finally)
+}
+
+func TestHooksSuccess(t *testing.T) {
+	log := new(hookLog)
+	h := log.hooks("seq", nil, nil)
+	seq := SequenceOf(hooked(func() error {
+		log.add("A")
+		return nil
+	}, log.hooks("A", nil, nil))).Before(h.Before).After(h.After).
+		OnError(h.OnError).Finally(h.Finally).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.Nil(t, stat.Err())
+	assert.Equal(t, []string{
+		"seq before", "A before", "A", "A after", "A finally", "seq after", "seq finally",
+	}, log.calls)
+}
+
+func TestHooksFailure(t *testing.T) {
+	log := new(hookLog)
+	failure := errors.New("A failure")
+	seq := SequenceOf(hooked(func() error {
+		return failure
+	}, log.hooks("A", nil, nil))).Then(
+		hooked(succeed, log.hooks("B", nil, nil)),
+	).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.Equal(t, &UnitError{"1", failure}, stat.Err())
+	assert.Equal(t, []string{"A before", "A on error: 1: A failure", "A finally"}, log.calls)
+}
+
+func TestBeforeHookFailure(t *testing.T) {
+	log := new(hookLog)
+	failure := errors.New("A failure")
+	seq := hooked(func() error {
+		log.add("A")
+		return nil
+	}, log.hooks("A", failure, nil)).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.Equal(t, &HookError{"before", "1", failure}, stat.Err())
+	assert.Equal(t, []string{
+		"A before", "A on error: before hook of 1 failed: A failure", "A finally",
+	}, log.calls)
+	assert.Equal(t, Failed, seq.Snapshot().Children[0].State)
+}
+
+func TestAfterHookFailure(t *testing.T) {
+	log := new(hookLog)
+	failure := errors.New("A failure")
+	seq := SequenceOf(hooked(succeed, log.hooks("A", nil, failure))).Then(
+		hooked(succeed, log.hooks("B", nil, nil)),
+	).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.Equal(t, &HookError{"after", "1", failure}, stat.Err())
+	assert.Equal(t, []string{
+		"A before", "A after", "A on error: after hook of 1 failed: A failure", "A finally",
+	}, log.calls)
+	assert.Equal(t, map[string]State{"": Failed, "1": Failed, "2": Skipped},
+		states(seq.Snapshot()))
+}
@@ -0,0 +1,134 @@
+package sequence
+
+import (
+	"context"
+	"time"
+
+	"github.com/nedp/command/status"
+)
+
+// A policy for running a phase repeatedly, such as to poll until a
+// health check passes.
+//
+// The loop ends when any of its limits is reached, when an iteration
+// fails, or when the status fails. A policy without MaxIterations,
+// While or Until repeats until it is stopped.
+type LoopPolicy struct {
+	// The maximum number of iterations; 0 for no limit.
+	MaxIterations int
+
+	// Checked before each iteration; the loop ends when it returns
+	// false. nil to not check.
+	While func(ctx context.Context) bool
+
+	// Checked after each iteration; the loop ends when it returns
+	// true. nil to not check.
+	Until func(ctx context.Context) bool
+
+	// The delay between iterations.
+	Interval time.Duration
+}
+
+// Calls `run` repeatedly, until the policy ends the loop.
+//
+// Before each iteration after the first, it sleeps for the policy's
+// interval and waits while the status is paused, so that pausing
+// and stopping the status take effect between iterations.
+// The iterations are counted in the node carried by `ctx`.
+// In a dry run, there is one iteration.
+//
+// Returns
+// the status returned by the last iteration, or `stat` if there
+// were none.
+func (lp LoopPolicy) run(ctx context.Context, stat status.Interface,
+	run func(context.Context, status.Interface) status.Interface) status.Interface {
+	n := nodeFrom(ctx)
+	if isDryRun(ctx) {
+		n.iterate()
+		return run(ctx, stat)
+	}
+	ran := false
+	for i := 0; lp.MaxIterations <= 0 || i < lp.MaxIterations; i += 1 {
+		if i > 0 && sleep(ctx, stat, lp.Interval) != nil {
+			break
+		}
+		// Don't start an iteration while paused, or after a failure.
+		if !stat.ReadyRLock() {
+			break
+		}
+		stat.RUnlock()
+		if lp.While != nil && !lp.While(ctx) {
+			break
+		}
+
+		n.iterate()
+		ran = true
+		stat = run(ctx, stat)
+		if state, _ := n.outcome(); state != Succeeded || stat.HasFailed() {
+			break
+		}
+		if lp.Until != nil && lp.Until(ctx) {
+			break
+		}
+	}
+
+	if !ran {
+		if stat.HasFailed() {
+			n.skip()
+		} else {
+			n.bypass()
+		}
+	}
+	return stat
+}
+
+// Appends a phase which is run repeatedly, according to `lp`.
+//
+// Each iteration runs the whole phase, including its sub-sequences
+// and hooks. The phase's node in a Snapshot describes its latest
+// iteration, and counts the iterations which have started.
+// If the loop ends before its first iteration, the phase is recorded
+// as skipped.
+//
+// Returns
+// a copy of the reciever with the looping phase added.
+func (sb SequenceBuilder) ThenLoop(lp LoopPolicy, pb PhaseBuilder) SequenceBuilder {
+	ph := pb.finish()
+	ph.loop = &lp
+	sb.phases = append(sb.phases, ph)
+	return sb
+}
+
+// Appends a phase which is run `n` times, unless an iteration fails.
+// If `n` isn't positive, the phase doesn't run, and is recorded as
+// skipped.
+//
+// Returns
+// a copy of the reciever with the looping phase added.
+func (sb SequenceBuilder) ThenRepeat(n int, pb PhaseBuilder) SequenceBuilder {
+	if n <= 0 {
+		// A MaxIterations of 0 would mean no limit.
+		return sb.ThenLoop(LoopPolicy{While: func(context.Context) bool {
+			return false
+		}}, pb)
+	}
+	return sb.ThenLoop(LoopPolicy{MaxIterations: n}, pb)
+}
+
+// Appends a phase which is run repeatedly while `cond` returns true
+// before each iteration.
+//
+// Returns
+// a copy of the reciever with the looping phase added.
+func (sb SequenceBuilder) ThenWhile(cond func(context.Context) bool, pb PhaseBuilder) SequenceBuilder {
+	return sb.ThenLoop(LoopPolicy{While: cond}, pb)
+}
+
+// Appends a phase which is run repeatedly until `cond` returns true
+// after an iteration.
+//
+// Returns
+// a copy of the reciever with the looping phase added.
+func (sb SequenceBuilder) ThenUntil(cond func(context.Context) bool, pb PhaseBuilder) SequenceBuilder {
+	return sb.ThenLoop(LoopPolicy{Until: cond}, pb)
+}
@@ -0,0 +1,179 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+func TestThenRepeat(t *testing.T) {
+	var nRan int32
+	seq := FirstJust(succeed).ThenRepeat(3, PhaseOf(func() error {
+		atomic.AddInt32(&nRan, 1)
+		return nil
+	}).Name("loop")).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, int32(3), nRan)
+	s := seq.Snapshot()
+	assert.Equal(t, 3, s.Children[1].Iterations)
+	assert.Equal(t, Succeeded, s.Children[1].State)
+	assert.Equal(t, 0, s.Children[0].Iterations)
+}
+
+func TestThenUntilWithInterval(t *testing.T) {
+	var nRan int32
+	healthy := func(context.Context) bool {
+		return atomic.LoadInt32(&nRan) == 3
+	}
+	seq := FirstJust(succeed).ThenLoop(LoopPolicy{
+		Until: healthy,
+		Interval: milliDuration,
+	}, PhaseOf(func() error {
+		atomic.AddInt32(&nRan, 1)
+		return nil
+	})).End()
+
+	start := time.Now()
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, int32(3), nRan)
+	assert.True(t, time.Since(start) >= 2*milliDuration)
+	assert.Equal(t, 3, seq.Snapshot().Children[1].Iterations)
+}
+
+func TestThenWhileNeverTrue(t *testing.T) {
+	ran := false
+	seq := FirstJust(succeed).ThenWhile(func(context.Context) bool {
+		return false
+	}, PhaseOf(func() error {
+		ran = true
+		return nil
+	})).ThenJust(succeed).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.False(t, ran)
+	assert.Equal(t, map[string]State{
+		"": Succeeded, "1": Succeeded, "2": Skipped, "3": Succeeded,
+	}, states(seq.Snapshot()))
+}
+
+func TestThenRepeatNone(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		ran := false
+		seq := FirstJust(succeed).ThenRepeat(n, PhaseOf(func() error {
+			ran = true
+			return nil
+		})).ThenJust(succeed).End()
+
+		stat := seq.RunAll(status.New())
+
+		assert.False(t, stat.HasFailed())
+		assert.False(t, ran, "Repeated %d times", n)
+		assert.Equal(t, map[string]State{
+			"": Succeeded, "1": Succeeded, "2": Skipped, "3": Succeeded,
+		}, states(seq.Snapshot()))
+	}
+}
+
+func TestLoopFailure(t *testing.T) {
+	failure := errors.New("A failure")
+	var nRan int32
+	seq := FirstJust(succeed).ThenRepeat(5, PhaseOf(func() error {
+		if atomic.AddInt32(&nRan, 1) == 2 {
+			return failure
+		}
+		return nil
+	})).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.True(t, stat.HasFailed())
+	assert.Equal(t, int32(2), nRan)
+	assert.Equal(t, &UnitError{"2", failure}, stat.Err())
+	assert.Equal(t, 2, seq.Snapshot().Children[1].Iterations)
+}
+
+func TestLoopPausedBetweenIterations(t *testing.T) {
+	stat := status.New()
+	var nRan int32
+	seq := FirstJust(succeed).ThenLoop(LoopPolicy{
+		MaxIterations: 2,
+		Interval: milliDuration,
+	}, PhaseOf(func() error {
+		if atomic.AddInt32(&nRan, 1) == 1 {
+			stat.Pause()
+		}
+		return nil
+	})).End()
+
+	done := make(chan struct{})
+	go func() {
+		seq.RunAll(stat)
+		close(done)
+	}()
+	time.Sleep(tinyDuration)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&nRan))
+
+	stat.Cont()
+	<-done
+	assert.Equal(t, int32(2), nRan)
+}
+
+func TestLoopStopped(t *testing.T) {
+	stat := status.New()
+	var nRan int32
+	seq := FirstJust(succeed).ThenLoop(LoopPolicy{
+		Interval: milliDuration,
+	}, PhaseOf(func() error {
+		if atomic.AddInt32(&nRan, 1) == 3 {
+			stat.Fail()
+		}
+		return nil
+	})).End()
+
+	seq.RunAll(stat)
+
+	assert.True(t, stat.HasFailed())
+	assert.Equal(t, int32(3), nRan)
+	assert.Equal(t, 3, seq.Snapshot().Children[1].Iterations)
+}
+
+func TestThenUntilFirstPass(t *testing.T) {
+	seq := FirstJust(succeed).ThenUntil(func(context.Context) bool {
+		return true
+	}, PhaseOf(succeed)).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	s := seq.Snapshot().Children[1]
+	assert.Equal(t, Succeeded, s.State)
+	assert.Equal(t, 1, s.Iterations)
+}
+
+func TestLoopFirstIterationFails(t *testing.T) {
+	failure := errors.New("A failure")
+	seq := FirstJust(succeed).ThenRepeat(3, PhaseOf(func() error {
+		return failure
+	})).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.True(t, stat.HasFailed())
+	s := seq.Snapshot().Children[1]
+	assert.Equal(t, Failed, s.State)
+	assert.Equal(t, &UnitError{"2", failure}, s.Err)
+	assert.Equal(t, 1, s.Iterations)
+}
@@ -0,0 +1,44 @@
+package sequence
+
+import (
+	"context"
+)
+
+// A Middleware wraps the execution of units of computation,
+// for behaviour such as logging, timing or tracing.
+//
+// It is passed the next unit in the chain, and returns a unit
+// which should call it. The Emitter passed to the unit identifies
+// the unit being run by its path.
+type Middleware func(next Unit) Unit
+
+type middlewareKey struct{}
+
+// Returns
+// a copy of `ctx` which carries the middleware it already carries,
+// followed by `mws`.
+func withMiddleware(ctx context.Context, mws []Middleware) context.Context {
+	if len(mws) == 0 {
+		return ctx
+	}
+	outer := middlewareFrom(ctx)
+	all := make([]Middleware, 0, len(outer)+len(mws))
+	all = append(append(all, outer...), mws...)
+	return context.WithValue(ctx, middlewareKey{}, all)
+}
+
+// Returns
+// the middleware carried by `ctx`, outermost first.
+func middlewareFrom(ctx context.Context) []Middleware {
+	mws, _ := ctx.Value(middlewareKey{}).([]Middleware)
+	return mws
+}
+
+// Returns
+// `u` wrapped by `mws`, the first of which is outermost.
+func chain(mws []Middleware, u Unit) Unit {
+	for i := len(mws) - 1; i >= 0; i -= 1 {
+		u = mws[i](u)
+	}
+	return u
+}
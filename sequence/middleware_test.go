@@ -0,0 +1,59 @@
+package sequence
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+// Returns
+// a middleware which logs `name` and the unit's path before
+// calling the unit.
+func logging(name string, log *[]string, mu *sync.Mutex) Middleware {
+	return func(next Unit) Unit {
+		return func(ctx context.Context, out *Emitter) error {
+			mu.Lock()
+			*log = append(*log, name+" "+out.Path())
+			mu.Unlock()
+			return next(ctx, out)
+		}
+	}
+}
+
+func TestMiddlewareInherited(t *testing.T) {
+	var mu sync.Mutex
+	var log []string
+	seq := SequenceOf(
+		PhaseOf(succeed).Use(logging("phase", &log, &mu)).And(
+			FirstJust(succeed).Use(logging("inner", &log, &mu)),
+		),
+	).ThenJust(succeed).Use(logging("seq", &log, &mu)).End()
+
+	stat := seq.RunAllWith(status.New(), RunOptions{
+		Middleware: []Middleware{logging("run", &log, &mu)},
+	})
+
+	assert.False(t, stat.HasFailed())
+	assert.ElementsMatch(t, []string{
+		"run 1", "seq 1", "phase 1",
+		"run 1/1/1", "seq 1/1/1", "phase 1/1/1", "inner 1/1/1",
+		"run 2", "seq 2",
+	}, log)
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var mu sync.Mutex
+	var log []string
+	seq := PhaseOfUnit(func(ctx context.Context, out *Emitter) error {
+		log = append(log, "unit")
+		return nil
+	}).Use(logging("A", &log, &mu), logging("B", &log, &mu)).End()
+
+	seq.RunAll(status.New())
+
+	assert.Equal(t, []string{"A 1", "B 1", "unit"}, log)
+}
@@ -0,0 +1,188 @@
+package sequence
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The output channel of a sequence, which is closed at the end of
+// each run and replaced for the next one.
+type outlet struct {
+	sync.RWMutex
+	ch chan Record
+
+	// Whether the channel of the current or next run has been
+	// given to a reader. Until it has, output is dropped.
+	attached bool
+}
+
+// Returns
+// the channel of the current run, or of the next run if the
+// sequence isn't running, which is read from by the caller.
+func (o *outlet) attach() chan Record {
+	o.Lock()
+	defer o.Unlock()
+	o.open()
+	o.attached = true
+	return o.ch
+}
+
+// Makes the channel of the next run, if there isn't one.
+//
+// Must be called with the lock held.
+func (o *outlet) open() {
+	if o.ch == nil {
+		o.ch = make(chan Record)
+	}
+}
+
+// Makes the channel of the run which is starting, if there isn't one.
+func (o *outlet) start() {
+	o.Lock()
+	defer o.Unlock()
+	o.open()
+}
+
+// Closes the channel of the current run,
+// so that the next run gets a new one.
+func (o *outlet) close() {
+	o.Lock()
+	defer o.Unlock()
+	if o.ch != nil {
+		close(o.ch)
+		o.ch = nil
+	}
+	o.attached = false
+}
+
+// Sends `r` to the channel of the current run.
+//
+// Drops `r` if nobody is reading the channel.
+// Doesn't block if the run has finished,
+// or once `ctx` is cancelled.
+func (o *outlet) send(ctx context.Context, r Record) {
+	o.RLock()
+	defer o.RUnlock()
+	if o.ch == nil || !o.attached {
+		return
+	}
+	select {
+	case o.ch <- r:
+	case <-ctx.Done():
+	}
+}
+
+type outletKey struct{}
+
+// Returns
+// a copy of `ctx` which carries `out`, the output channel
+// of the sequence being run.
+func withOutlet(ctx context.Context, out *outlet) context.Context {
+	return context.WithValue(ctx, outletKey{}, out)
+}
+
+// An Emitter sends the output of a unit of computation to the
+// sequence's output channel, attributed to the unit's path.
+//
+// Units added with the Unit variants of the builder methods are
+// passed their Emitter, instead of capturing a channel.
+type Emitter struct {
+	ctx context.Context
+	name string
+	path string
+}
+
+// Returns
+// the Emitter for the unit whose context is `ctx`.
+func emitterFrom(ctx context.Context) *Emitter {
+	n := nodeFrom(ctx)
+	return &Emitter{ctx, n.name, n.path}
+}
+
+// Returns
+// the path of the unit in the sequence. See `UnitError`.
+func (e *Emitter) Path() string {
+	return e.path
+}
+
+// Returns
+// the name of the unit's phase, or "" if it has none.
+func (e *Emitter) Name() string {
+	return e.name
+}
+
+// Sends `r` to the output channel, attributed to the unit.
+//
+// The record's Time and Path are set if they're zero.
+// Doesn't block if the sequence's output isn't being read,
+// or once the unit's context is cancelled.
+func (e *Emitter) Send(r Record) {
+	out, _ := e.ctx.Value(outletKey{}).(*outlet)
+	if out == nil {
+		return
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	if r.Path == "" {
+		r.Path = e.path
+	}
+	out.send(e.ctx, r)
+}
+
+// Sends `s` to the output channel as an informational record.
+func (e *Emitter) Emit(s string) {
+	e.Send(Record{Text: s})
+}
+
+// Sends `s` to the output channel with the severity `sev`.
+func (e *Emitter) Log(sev Severity, s string) {
+	e.Send(Record{Severity: sev, Text: s})
+}
+
+// Formats according to `format` and emits the result.
+func (e *Emitter) Printf(format string, args ...interface{}) {
+	e.Emit(fmt.Sprintf(format, args...))
+}
+
+// Emits each line of `p`, so that the Emitter may be used
+// as an `io.Writer`.
+//
+// Returns
+// len(p), nil
+func (e *Emitter) Write(p []byte) (int, error) {
+	e.write(StreamInfo, p)
+	return len(p), nil
+}
+
+// Returns
+// an `io.Writer` which sends each line written to it as a record
+// of the stream `s`.
+func (e *Emitter) Stream(s Stream) io.Writer {
+	return streamWriter{e, s}
+}
+
+func (e *Emitter) write(s Stream, p []byte) {
+	for _, line := range strings.Split(strings.TrimSuffix(string(p), "\n"), "\n") {
+		e.Send(Record{Stream: s, Text: line})
+	}
+}
+
+type streamWriter struct {
+	e *Emitter
+	s Stream
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w.e.write(w.s, p)
+	return len(p), nil
+}
+
+// Sends `r` on behalf of the unit whose context is `ctx`.
+func emit(ctx context.Context, r Record) {
+	emitterFrom(ctx).Send(r)
+}
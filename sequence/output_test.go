@@ -0,0 +1,127 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+// Collects the output of the next run of `seq`.
+//
+// Returns
+// a channel which recieves the output once the run finishes.
+func collect(seq Sequence) <-chan []string {
+	in := seq.OutputChannel()
+	out := make(chan []string, 1)
+	go func() {
+		var lines []string
+		for s := range in {
+			lines = append(lines, s)
+		}
+		out <- lines
+	}()
+	return out
+}
+
+func TestEmitterAttribution(t *testing.T) {
+	emitPath := func(ctx context.Context, out *Emitter) error {
+		out.Emit(out.Path())
+		return nil
+	}
+	seq := FirstJustUnit(emitPath).Then(
+		PhaseOfUnit(emitPath).And(
+			FirstJustUnit(emitPath).ThenJustUnit(emitPath),
+		).AndJustUnit(func(ctx context.Context, out *Emitter) error {
+			fmt.Fprintln(out, "A")
+			out.Printf("%s", "B")
+			return nil
+		}),
+	).End()
+
+	out := collect(seq)
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	lines := <-out
+	sort.Strings(lines)
+	assert.Equal(t, []string{
+		"1: 1", "2/1/1: 2/1/1", "2/1/2: 2/1/2", "2/2/1: A", "2/2/1: B", "2: 2",
+	}, lines)
+}
+
+func TestOutputChannelClosed(t *testing.T) {
+	seq := PhaseOfUnit(func(ctx context.Context, out *Emitter) error {
+		out.Emit("A")
+		return nil
+	}).End()
+
+	for i := 0; i < 2; i++ {
+		out := collect(seq)
+		seq.RunAll(status.New())
+		assert.Equal(t, []string{"1: A"}, <-out)
+	}
+}
+
+func TestOutputWithoutReader(t *testing.T) {
+	attempts := 0
+	seq := PhaseOfUnit(func(ctx context.Context, out *Emitter) error {
+		out.Emit("A")
+		attempts += 1
+		if attempts < 2 {
+			return errors.New("A failure")
+		}
+		return nil
+	}).Retry(RetryPolicy{MaxAttempts: 2}).End()
+
+	done := make(chan status.Interface)
+	go func() {
+		done <- seq.RunAll(status.New())
+	}()
+
+	// The output and the retry warning are dropped.
+	select {
+	case stat := <-done:
+		assert.False(t, stat.HasFailed())
+	case <-time.After(tinyDuration):
+		t.Fatal("The run blocked on output which nobody reads")
+	}
+}
+
+func TestEmitterRecords(t *testing.T) {
+	seq := PhaseOfUnit(func(ctx context.Context, out *Emitter) error {
+		out.Log(SeverityWarning, "A")
+		fmt.Fprint(out.Stream(StreamStderr), "B\nC\n")
+		return nil
+	}).End()
+
+	records := make(chan []Record, 1)
+	go func(in <-chan Record) {
+		var rs []Record
+		for r := range in {
+			rs = append(rs, r)
+		}
+		records <- rs
+	}(seq.Records())
+	start := time.Now()
+	seq.RunAll(status.New())
+
+	rs := <-records
+	if assert.Len(t, rs, 3) {
+		for _, r := range rs {
+			assert.Equal(t, "1", r.Path)
+			assert.WithinDuration(t, start, r.Time, tinyDuration)
+		}
+		assert.Equal(t, SeverityWarning, rs[0].Severity)
+		assert.Equal(t, StreamInfo, rs[0].Stream)
+		assert.Equal(t, Record{Path: "1", Stream: StreamStderr, Text: "B"},
+			Record{Path: rs[1].Path, Stream: rs[1].Stream, Text: rs[1].Text})
+		assert.Equal(t, "1: C", rs[2].String())
+	}
+}
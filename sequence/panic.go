@@ -0,0 +1,54 @@
+package sequence
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/nedp/command/status"
+)
+
+// PanicError is recorded as the cause of a failure when a unit of
+// computation, or a function run around it, panics.
+type PanicError struct {
+	// The value passed to panic.
+	Value interface{}
+
+	// The stack trace of the goroutine which panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Returns
+// the value passed to panic if it was an error, or nil.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Returns
+// a function which calls `fn`, returning a `*PanicError`
+// if `fn` panics.
+func protect(fn func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = &PanicError{v, debug.Stack()}
+			}
+		}()
+		return fn(ctx)
+	}
+}
+
+// Records a failure caused by a `*PanicError` in `stat`
+// if the caller is panicking, stopping the panic.
+//
+// Must be deferred directly.
+func recoverInto(stat status.Interface) {
+	if v := recover(); v != nil {
+		_ = stat.FailWith(&PanicError{v, debug.Stack()})
+	}
+}
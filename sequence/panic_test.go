@@ -0,0 +1,82 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+func TestPanicInSubSequence(t *testing.T) {
+	ranLast := false
+	seq := SequenceOf(PhaseOf(succeed).AndJust(func() error {
+		panic("A panic")
+	}).AndJust(succeed)).ThenJust(func() error {
+		ranLast = true
+		return nil
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.True(t, stat.HasFailed())
+	assert.False(t, ranLast, "The phase after the panic ran")
+	var panicErr *PanicError
+	if assert.ErrorAs(t, stat.Err(), &panicErr) {
+		assert.Equal(t, "A panic", panicErr.Value)
+		assert.Contains(t, string(panicErr.Stack), "panic_test.go")
+	}
+	assert.EqualError(t, stat.Err(), "1/1/1: panic: A panic")
+}
+
+func TestPanicInHook(t *testing.T) {
+	seq := SequenceOf(PhaseOf(succeed).And(
+		FirstJust(succeed).Finally(func(context.Context) {
+			panic("A panic")
+		}),
+	)).End()
+
+	stat := seq.RunAll(status.New())
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, stat.Err(), &panicErr)
+}
+
+func TestPanicInRootHook(t *testing.T) {
+	failure := errors.New("A failure")
+	seq := FirstJust(succeed).Before(func(context.Context) error {
+		panic(failure)
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.ErrorIs(t, stat.Err(), failure)
+}
+
+func TestPanicWithTimeout(t *testing.T) {
+	seq := PhaseOf(func() error {
+		panic("A panic")
+	}).Timeout(shortDuration).End()
+
+	stat := seq.RunAll(status.New())
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, stat.Err(), &panicErr)
+}
+
+func TestPanicInCompensation(t *testing.T) {
+	seq := SequenceOf(PhaseOf(succeed).Compensate(func() error {
+		panic("A panic")
+	})).ThenJust(func() error {
+		return errors.New("A failure")
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, stat.Err(), &panicErr)
+	var compErr *CompensationError
+	assert.ErrorAs(t, stat.Err(), &compErr)
+}
@@ -0,0 +1,63 @@
+package sequence
+
+import (
+	"strconv"
+)
+
+// UnitError is recorded when a unit of computation returns an error,
+// identifying the unit by its path.
+//
+// A path is made from the names of the unit's enclosing phases and
+// sequences, separated by "/". Elements without names are identified
+// by their position in their parent, counting from 1.
+type UnitError struct {
+	Path string
+	Err error
+}
+
+func (e *UnitError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *UnitError) Unwrap() error {
+	return e.Err
+}
+
+// Implemented by the elements of a sequence, which may have names.
+type namer interface {
+	unitName() string
+}
+
+func (ph phase) unitName() string {
+	return ph.name
+}
+
+func (seq sequence) unitName() string {
+	return seq.name
+}
+
+// Returns
+// the name of `r` if it has one, or "".
+func nameOf(r runAller) string {
+	if n, ok := r.(namer); ok {
+		return n.unitName()
+	}
+	return ""
+}
+
+// Returns
+// the path of the `i`th child, counting from 0, of the element
+// at `parent`, which is named `name` if `name` isn't "".
+func childPath(parent string, i int, name string) string {
+	segment := name
+	if segment == "" {
+		segment = strconv.Itoa(i + 1)
+	}
+	if parent == "" {
+		return segment
+	}
+	return parent + "/" + segment
+}
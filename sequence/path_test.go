@@ -0,0 +1,57 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+func TestNamedPaths(t *testing.T) {
+	emitPath := func(ctx context.Context, out *Emitter) error {
+		out.Emit(out.Name())
+		return nil
+	}
+	failure := errors.New("A failure")
+	seq := SequenceOf(
+		PhaseOfUnit(emitPath).Name("setup").And(
+			FirstJustUnit(emitPath).Name("db-migrate"),
+		).AndJustUnit(emitPath),
+	).ThenJust(func() error {
+		return failure
+	}).Name("deploy").End()
+
+	out := collect(seq)
+	stat := seq.RunAll(status.New())
+
+	assert.Equal(t, &UnitError{"deploy/2", failure}, stat.Err())
+	assert.EqualError(t, stat.Err(), "deploy/2: A failure")
+	lines := <-out
+	sort.Strings(lines)
+	assert.Equal(t, []string{
+		"deploy/setup/2/1: ",
+		"deploy/setup/db-migrate/1: ",
+		"deploy/setup: setup",
+	}, lines)
+}
+
+func TestCompensationErrorPath(t *testing.T) {
+	undoFailure := errors.New("An undo failure")
+	seq := SequenceOf(PhaseOf(succeed).Name("A").Compensate(func() error {
+		return undoFailure
+	})).ThenJust(func() error {
+		return errors.New("A failure")
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	var compErr *CompensationError
+	if assert.ErrorAs(t, stat.Err(), &compErr) {
+		assert.Equal(t, "A", compErr.Path)
+		assert.EqualError(t, compErr, "compensation of A failed: An undo failure")
+	}
+}
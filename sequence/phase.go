@@ -2,59 +2,253 @@ package sequence
 
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/nedp/command/status"
 )
 
 type runAller interface {
-	runAll(status status.Interface) status.Interface
+	runAll(ctx context.Context, status status.Interface) status.Interface
 }
 
 type phase struct {
+	name string
 	sequences []runAller
-	main func() error
+	main Unit
+
+	// Produces more sequences each time the phase runs, or nil.
+	expand func(context.Context) ([]runAller, error)
+
+	// Decides whether the phase runs, or nil if it always does.
+	cond condition
+
+	// Identifies the phase in a chain of conditional phases,
+	// or nil if it isn't one. See `ThenIf`.
+	branch *int
+
+	// How the phase is repeated, or nil if it runs once.
+	loop *LoopPolicy
+
+	// Describes the main function in a dry run, or nil.
+	description func(context.Context) string
+
+	// The time limit of each attempt of the main function,
+	// or 0 for no limit.
+	timeout time.Duration
+
+	retry RetryPolicy
+
+	policy FailurePolicy
+
+	// The number of sub-sequences which must succeed, or 0.
+	quorum int
+
+	// The number of sub-sequences which may run at once,
+	// or 0 for no limit.
+	maxParallel int
+
+	// Undoes the main function after a later failure, or nil.
+	compensate func(context.Context) error
+
+	hooks Hooks
+	middleware []Middleware
 }
 
-func (ph phase) runAll(stat status.Interface) status.Interface {
+func (ph phase) runAll(ctx context.Context, stat status.Interface) status.Interface {
+	if !ph.cond.allows(ctx, stat) {
+		return stat
+	}
+	if ph.loop != nil {
+		return ph.loop.run(ctx, stat, ph.runOnce)
+	}
+	return ph.runOnce(ctx, stat)
+}
+
+func (ph phase) runOnce(ctx context.Context, stat status.Interface) status.Interface {
+	return ph.hooks.around(ctx, stat, ph.runPhase)
+}
+
+func (ph phase) runPhase(ctx context.Context, stat status.Interface) status.Interface {
+	ctx = withMiddleware(ctx, ph.middleware)
+	if ph.expand != nil && !isDryRun(ctx) {
+		var ok bool
+		if ph, ok = ph.expanded(ctx, stat); !ok {
+			return stat
+		}
+	}
+	n := nodeFrom(ctx)
 	// Wait for previous computations to end before starting new ones.
 	// Don't allow status access during the setup period
 	// of this phase's operations.
 	if !stat.ReadyRLock() {
+		n.skip()
 		return stat
 	}
-	stat = ph.runSequences(stat)
+	n.begin()
+	stat.Publish(status.Event{Kind: status.EventPhaseEntered, Path: n.path})
+	var out *outcomes
+	if ph.isolatesSequences() {
+		out = new(outcomes)
+	}
+	stat = ph.runSequences(ctx, stat, out)
 
 	// Setup period over, status is now accessible safely.
 	stat.RUnlock()
 
-	// If this operation has an error, return a failed status.
-	if ph.main() != nil {
-		_ = stat.Fail() // Don't care if a failure already occured.
-		return stat
+	if ph.main == nil {
+		ph.withoutMain(ctx)
+	} else {
+		ph.runUnit(ctx, stat)
+	}
+
+	// Wait for all child sequences to finish, so that any errors
+	// they encounter are recorded before returning.
+	storeFrom(ctx).idle(stat.Wait)
+	if out != nil {
+		n.fail(out.judge(stat, ph.quorum, len(ph.sequences)), false)
 	}
+	n.finish(ctx, ph.isolatesSequences())
+	stat.Publish(status.Event{Kind: status.EventPhaseExited, Path: n.path, Err: n.failure()})
 
-	// Block until "ready" (all child sequences finish).
+	// Block until "ready" (not paused).
 	if stat.ReadyRLock() {
 		stat.RUnlock()
 	}
 	return stat
 }
 
-func (ph phase) runSequences(stat status.Interface) status.Interface {
+// Runs the main function, reporting it as a unit.
+//
+// If it fails, the failure is recorded in the status.
+// Otherwise, it may need to be compensated for later.
+func (ph phase) runUnit(ctx context.Context, stat status.Interface) {
+	n := nodeFrom(ctx)
+	stat.Publish(status.Event{Kind: status.EventUnitStarted, Path: n.path})
+	if err := ph.runMain(ctx, stat); err != nil {
+		err = attribute(ctx, err)
+		stat.Publish(status.Event{Kind: status.EventUnitFailed, Path: n.path, Err: err})
+		n.fail(err, ph.policy == ContinueOnError)
+		if ph.policy == ContinueOnError {
+			stat.Report(err)
+		} else {
+			_ = stat.FailWith(err) // Don't care if a failure already occured.
+		}
+	} else {
+		stat.Publish(status.Event{Kind: status.EventUnitFinished, Path: n.path})
+		if !isDryRun(ctx) {
+			n.completed(ph.compensate)
+		}
+	}
+}
+
+// Stands in for the main function of a phase which has none,
+// such as one built by ForEach, without reporting a unit.
+func (ph phase) withoutMain(ctx context.Context) {
+	switch {
+	case !isDryRun(ctx):
+		nodeFrom(ctx).completed(ph.compensate)
+	case ph.description != nil:
+		emit(ctx, Record{Severity: SeverityInfo, Text: ph.description(ctx)})
+	}
+}
+
+// Starts the phase's sequences.
+//
+// If `out` isn't nil, each sequence is isolated,
+// and its outcome is recorded in `out`.
+func (ph phase) runSequences(ctx context.Context, stat status.Interface,
+	out *outcomes) status.Interface {
+	n := nodeFrom(ctx)
+	s := storeFrom(ctx)
+	var slots semaphore
+	if ph.maxParallel > 0 {
+		slots = make(semaphore, ph.maxParallel)
+	}
 	stat.Add(len(ph.sequences))
 	// Run each child sequence with a new status object.
 	// Use a new status object so that different child sequences
 	// don't wait on eachother.
-	for _, seq := range ph.sequences {
+	for i, seq := range ph.sequences {
 		if stat.HasFailed() {
+			// Sequences which won't be started are done already.
+			stat.Add(i - len(ph.sequences))
+			for j := i; j < len(ph.sequences); j += 1 {
+				n.child(j, ph.sequences[j]).skip()
+			}
 			break
 		}
-		go func(boundCopy status.Interface, seq runAller) {
-			seq.runAll(boundCopy)
-
-			// Mark this sequence as done.
+		s.enter()
+		go func(ctx context.Context, boundCopy status.Interface, seq runAller) {
+			// Mark this sequence as done, even if it panics.
 			// If there was a failure, it propogates automatically.
-			stat.Done()
-		}(stat.BoundCopy(), seq)
+			defer stat.Done()
+			defer s.leave()
+			defer recoverInto(boundCopy)
+
+			// Wait for a slot, unless the phase fails first.
+			if slots != nil {
+				if !slots.acquire(ctx, boundCopy) {
+					nodeFrom(ctx).skip()
+					return
+				}
+				defer slots.release()
+			}
+
+			if out == nil {
+				seq.runAll(ctx, boundCopy)
+			} else {
+				out.record(isolate(ctx, boundCopy, seq.runAll))
+			}
+		}(withNode(ctx, n.child(i, seq)), stat.BoundCopy(), seq)
 	}
 	return stat
 }
+
+// Whether the phase's sequences are isolated from eachother.
+func (ph phase) isolatesSequences() bool {
+	return ph.policy != FailFast || ph.quorum > 0
+}
+
+// Makes one attempt of the main function,
+// within its time limit if it has one.
+//
+// If the run has a limit of Workers, the attempt waits for one first.
+func (ph phase) call(ctx context.Context) error {
+	if w := workersFrom(ctx); w != nil {
+		stat, _ := statusFrom(ctx)
+		var h *hold
+		if ctx, h = w.hold(ctx, stat); h == nil {
+			return context.Cause(ctx)
+		}
+		defer h.free()
+	}
+	if ph.timeout <= 0 {
+		return ph.run(ctx)
+	}
+	return within(ctx, time.Now().Add(ph.timeout), ph.run)
+}
+
+// Calls the main function with its Emitter,
+// wrapped by the middleware carried by `ctx`.
+// A panic is returned as a `*PanicError`.
+func (ph phase) run(ctx context.Context) error {
+	return protect(func(ctx context.Context) error {
+		return chain(middlewareFrom(ctx), ph.main)(ctx, emitterFrom(ctx))
+	})(ctx)
+}
+
+// Attributes `err` to the cancellation of `ctx` if it was returned
+// in response to one, so that it is reported as the cause of the
+// cancellation. Otherwise, attributes it to the unit being run,
+// even if `ctx` was cancelled, so that errors of their own aren't
+// lost.
+func attribute(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		if cause := context.Cause(ctx); errors.Is(err, ctx.Err()) || errors.Is(err, cause) {
+			return cause
+		}
+	}
+	return &UnitError{nodeFrom(ctx).path, err}
+}
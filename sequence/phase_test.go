@@ -1,6 +1,7 @@
 package sequence
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -69,10 +70,41 @@ func (s *statusMock) ReadyRLock() bool {
 	return args.Bool(0)
 }
 
+func (s *statusMock) RLock() {
+	s.Called()
+}
+
 func (s *statusMock) RUnlock() {
 	s.Called()
 }
 
+func (s *statusMock) Wait() {
+	s.Called()
+	n := <-s.ch
+	for n > 0 {
+		s.ch <- n
+		time.Sleep(microDuration)
+		n = <-s.ch
+	}
+	s.ch <- n
+}
+
+func (s *statusMock) IsPaused() bool {
+	return s.Called().Bool(0)
+}
+
+func (s *statusMock) Paused() <-chan struct{} {
+	s.Called()
+	return make(chan struct{})
+}
+
+func (s *statusMock) Unpaused() <-chan struct{} {
+	s.Called()
+	unpaused := make(chan struct{})
+	close(unpaused)
+	return unpaused
+}
+
 func (s *statusMock) Pause() (bool, error) {
 	args := s.Called()
 	return args.Bool(0), args.Error(1)
@@ -88,12 +120,44 @@ func (s *statusMock) Fail() error {
 	return args.Error(0)
 }
 
+func (s *statusMock) FailWith(err error) error {
+	args := s.Called(err)
+	return args.Error(0)
+}
+
+func (s *statusMock) Err() error {
+	args := s.Called()
+	return args.Error(0)
+}
+
+func (s *statusMock) Context() context.Context {
+	return context.Background()
+}
+
+func (s *statusMock) Subscribe(size int) (<-chan status.Event, func()) {
+	args := s.Called(size)
+	return args.Get(0).(<-chan status.Event), args.Get(1).(func())
+}
+
+// Events aren't part of the phase's expected interactions.
+func (s *statusMock) Publish(status.Event) {
+}
+
+func (s *statusMock) Report(err error) {
+	s.Called(err)
+}
+
+func (s *statusMock) IsolatedCopy() status.Interface {
+	s.Called()
+	return s.boundCopy
+}
+
 func (s *statusMock) HasFailed() bool {
 	s.hasFailed = s.Called().Bool(0)
 	return s.hasFailed
 }
 
-func (ra *runAllerMock) runAll(stat status.Interface) status.Interface {
+func (ra *runAllerMock) runAll(ctx context.Context, stat status.Interface) status.Interface {
 	args := ra.Called(stat)
 	fuse := time.After(ra.duration)
 	<-fuse
@@ -115,7 +179,7 @@ func TestRunSequencesSuccess(t *testing.T) {
 
 	const testDuration = shortDuration
 	phase := new(phase)
-	phase.main = func() error { return nil }
+	phase.main = func(context.Context, *Emitter) error { return nil }
 	phase.sequences = make([]runAller, nSequences)
 	const iFailure = nSequences / 2
 	for i := 0; i < nSequences; i += 1 {
@@ -126,7 +190,7 @@ func TestRunSequencesSuccess(t *testing.T) {
 
 	// Verify that the sequences were started in separate goroutines
 	start := time.Now()
-	phase.runSequences(stat)
+	phase.runSequences(context.Background(), stat, nil)
 	assert.WithinDuration(t, time.Now(), start, milliDuration,
 		"runSequences took too long")
 
@@ -152,6 +216,8 @@ func TestRunSequencesFailure(t *testing.T) {
 	boundCopy.isReflection = true
 	stat.boundCopy = status.Interface(boundCopy)
 	stat.On("Add", nSequences).Return().Once()
+	// The sequences after the failure are never started.
+	stat.On("Add", iFailure+1-nSequences).Return().Once()
 	stat.ch = make(chan int, 1)
 	stat.ch <- 0
 	stat.On("Done").Return()
@@ -163,7 +229,7 @@ func TestRunSequencesFailure(t *testing.T) {
 	const testDuration = shortDuration
 	phase := new(phase)
 	nCalls := make(chan int)
-	phase.main = func() error {
+	phase.main = func(context.Context, *Emitter) error {
 		nCalls <- (<-nCalls) - 1
 		return nil
 	}
@@ -179,7 +245,7 @@ func TestRunSequencesFailure(t *testing.T) {
 
 	// Verify that the sequences were started in separate goroutines
 	start := time.Now()
-	phase.runSequences(stat)
+	phase.runSequences(context.Background(), stat, nil)
 	assert.WithinDuration(t, time.Now(), start, milliDuration,
 		"runSequences took too long")
 
@@ -213,14 +279,15 @@ func TestRunAllSimple(t *testing.T) {
 	stat.On("ReadyRLock").Return(true).Times(2)
 	stat.On("RUnlock").Return().Times(2)
 	stat.On("Add", 0).Return().Once()
+	stat.On("Wait").Return().Once()
 
 	ph := phase{}
-	ph.main = func() error {
+	ph.main = func(context.Context, *Emitter) error {
 		return nil
 	}
 	ph.sequences = []runAller{}
 
-	stat = ph.runAll(stat).(*statusMock)
+	stat = ph.runAll(context.Background(), stat).(*statusMock)
 
 	// Validate expectations
 	assert.False(t, stat.hasFailed, "RunAll reported unexpected failure")
@@ -234,21 +301,24 @@ func TestRunAllFailure(t *testing.T) {
 	stat := new(statusMock)
 	stat.ch = make(chan int, 1)
 	stat.ch <- 0
-	stat.On("ReadyRLock").Return(true).Times(1)
+	stat.On("ReadyRLock").Return(true).Times(2)
 	stat.On("RUnlock").Return().Times(1)
 	stat.On("Add", 1).Return().Once()
-	stat.On("Fail").Return(nil).Once()
+	stat.On("Add", -1).Return().Once()
 	stat.On("HasFailed").Return(true).Once()
+	stat.On("Wait").Return().Once()
 
 	seq := new(runAllerMock)
 
 	ph := phase{}
-	ph.main = func() error {
-		return errors.New("Failure")
+	failure := errors.New("Failure")
+	stat.On("FailWith", &UnitError{"", failure}).Return(nil).Once()
+	ph.main = func(context.Context, *Emitter) error {
+		return failure
 	}
 	ph.sequences = []runAller{seq}
 
-	stat = ph.runAll(stat).(*statusMock)
+	stat = ph.runAll(context.Background(), stat).(*statusMock)
 
 	// Validate expectations
 	assert.True(t, stat.hasFailed, "RunAll reported unexpected success")
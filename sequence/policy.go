@@ -0,0 +1,144 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nedp/command/status"
+)
+
+// A FailurePolicy selects what happens when a function in a phase
+// or sequence returns an error.
+//
+// Failures which don't propagate to the whole run, under
+// ContinueOnError or Isolate, aren't compensated for.
+// See `PhaseBuilder.Compensate`.
+type FailurePolicy int
+
+const (
+	// Any error fails the whole shared status, and nothing else runs.
+	// This is the default.
+	FailFast FailurePolicy = iota
+
+	// Errors are recorded without failing, and running continues.
+	//
+	// For a phase, errors from the main function are recorded, and
+	// its sub-sequences are isolated.
+	// For a sequence, each phase is isolated, and the next phase
+	// runs even if the previous one failed.
+	ContinueOnError
+
+	// Failures don't propagate beyond the isolated computations,
+	// but are still recorded.
+	//
+	// For a phase, each of its sub-sequences is isolated from its
+	// siblings and the phase; a failure of the main function still
+	// fails the phase.
+	// For a sequence, the whole sequence is isolated from its
+	// siblings and its parent.
+	Isolate
+)
+
+// QuorumError is recorded as the cause of a failure when fewer of a
+// phase's sub-sequences succeed than its quorum requires.
+type QuorumError struct {
+	Quorum    int
+	Succeeded int
+
+	// The number of the phase's sub-sequences.
+	Total int
+
+	// The errors of the sub-sequences which failed.
+	Errs []error
+}
+
+func (e *QuorumError) Error() string {
+	if e.Quorum > e.Total {
+		return fmt.Sprintf("the quorum of %d is unreachable with %d sub-sequences",
+			e.Quorum, e.Total)
+	}
+	return fmt.Sprintf("%d of the required %d sub-sequences succeeded: %v",
+		e.Succeeded, e.Quorum, errors.Join(e.Errs...))
+}
+
+func (e *QuorumError) Unwrap() []error {
+	return e.Errs
+}
+
+// Runs `run` with an isolated copy of `stat`, so that a failure
+// doesn't fail `stat`.
+//
+// Returns
+// whether the run failed;
+// the errors recorded by the run, including tolerated errors.
+func isolate(ctx context.Context, stat status.Interface,
+	run func(context.Context, status.Interface) status.Interface) (bool, error) {
+	iso := stat.IsolatedCopy()
+
+	// Cancel the run's context when the isolated copy fails.
+	isoCtx := iso.Context()
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(isoCtx, func() {
+		cancel(context.Cause(isoCtx))
+	})
+	defer stop()
+	defer cancel(nil)
+
+	iso = run(ctx, iso)
+	return iso.HasFailed(), iso.Err()
+}
+
+// The outcomes of a phase's isolated sub-sequences.
+type outcomes struct {
+	sync.Mutex
+	nSucceeded int
+
+	// The errors of failed sub-sequences.
+	failures []error
+
+	// The errors tolerated by sub-sequences which succeeded.
+	tolerated []error
+}
+
+// Records the outcome of an isolated sub-sequence.
+func (o *outcomes) record(failed bool, err error) {
+	o.Lock()
+	defer o.Unlock()
+	switch {
+	case failed:
+		o.failures = append(o.failures, err)
+	case err != nil:
+		o.tolerated = append(o.tolerated, err)
+		o.nSucceeded += 1
+	default:
+		o.nSucceeded += 1
+	}
+}
+
+// Records the outcomes in `stat`, once all sub-sequences are done.
+//
+// Records a failure with a `*QuorumError` if fewer than `quorum`
+// of the `total` sub-sequences succeeded, which is certain if
+// `quorum` is greater than `total`; otherwise the errors of the
+// failed sub-sequences are only reported.
+//
+// Returns
+// the `*QuorumError` if one was recorded, or nil.
+func (o *outcomes) judge(stat status.Interface, quorum, total int) error {
+	o.Lock()
+	defer o.Unlock()
+	for _, err := range o.tolerated {
+		stat.Report(err)
+	}
+	if o.nSucceeded < quorum && !stat.HasFailed() {
+		err := &QuorumError{quorum, o.nSucceeded, total, o.failures}
+		_ = stat.FailWith(err)
+		return err
+	}
+	for _, err := range o.failures {
+		stat.Report(err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package sequence
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+func TestContinueOnErrorPhase(t *testing.T) {
+	failure := errors.New("A failure")
+	var nRan int32
+	seq := SequenceOf(PhaseOf(func() error {
+		return failure
+	}).AndJust(func() error {
+		atomic.AddInt32(&nRan, 1)
+		return failure
+	}).Policy(ContinueOnError)).ThenJust(func() error {
+		atomic.AddInt32(&nRan, 1)
+		return nil
+	})
+
+	stat := seq.End().RunAll(status.New())
+
+	assert.False(t, stat.HasFailed(), "The tolerated errors caused a failure")
+	assert.Equal(t, int32(2), nRan)
+	assert.ErrorIs(t, stat.Err(), failure)
+}
+
+func TestContinueOnErrorSequence(t *testing.T) {
+	failure := errors.New("A failure")
+	ranLast := false
+	seq := FirstJust(func() error {
+		return failure
+	}).ThenJust(func() error {
+		ranLast = true
+		return nil
+	}).Policy(ContinueOnError).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed(), "The tolerated error caused a failure")
+	assert.True(t, ranLast, "The phase after the failure didn't run")
+	assert.Equal(t, &UnitError{"1", failure}, stat.Err())
+}
+
+func TestIsolateSequence(t *testing.T) {
+	failure := errors.New("A failure")
+	var nRan int32
+	seq := SequenceOf(PhaseOf(func() error {
+		return nil
+	}).And(FirstJust(func() error {
+		return failure
+	}).ThenJust(func() error {
+		t.Error("The isolated sequence continued after its failure")
+		return nil
+	}).Policy(Isolate)).AndJust(func() error {
+		atomic.AddInt32(&nRan, 1)
+		return nil
+	})).ThenJust(func() error {
+		atomic.AddInt32(&nRan, 1)
+		return nil
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed(), "The isolated failure propagated")
+	assert.Equal(t, int32(2), nRan)
+	assert.Equal(t, &UnitError{"1/1/1", failure}, stat.Err())
+}
+
+func TestIsolatePhase(t *testing.T) {
+	failure := errors.New("A failure")
+	seq := PhaseOf(func() error {
+		return nil
+	}).AndJust(func() error {
+		return failure
+	}).AndJust(func() error {
+		return nil
+	}).Policy(Isolate).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed(), "The isolated failure propagated")
+	assert.Equal(t, &UnitError{"1/1/1", failure}, stat.Err())
+}
+
+func testQuorum(t *testing.T, quorum int) status.Interface {
+	failure := errors.New("A failure")
+	pb := PhaseOf(func() error { return nil })
+	for i := 0; i < 5; i += 1 {
+		fails := i < 2
+		pb = pb.AndJust(func() error {
+			if fails {
+				return failure
+			}
+			return nil
+		})
+	}
+	return pb.Quorum(quorum).End().RunAll(status.New())
+}
+
+func TestQuorumMet(t *testing.T) {
+	stat := testQuorum(t, 3)
+	assert.False(t, stat.HasFailed(), "Failed despite meeting the quorum")
+	assert.NotNil(t, stat.Err(), "Didn't report the tolerated errors")
+}
+
+func TestQuorumNotMet(t *testing.T) {
+	stat := testQuorum(t, 4)
+	assert.True(t, stat.HasFailed(), "Didn't fail despite missing the quorum")
+
+	var quorumErr *QuorumError
+	if assert.ErrorAs(t, stat.Err(), &quorumErr) {
+		assert.Equal(t, 4, quorumErr.Quorum)
+		assert.Equal(t, 3, quorumErr.Succeeded)
+		assert.Len(t, quorumErr.Errs, 2)
+	}
+}
+
+func TestQuorumUnreachable(t *testing.T) {
+	stat := testQuorum(t, 6)
+
+	var quorumErr *QuorumError
+	if assert.ErrorAs(t, stat.Err(), &quorumErr) {
+		assert.Equal(t, 5, quorumErr.Total)
+		assert.EqualError(t, quorumErr, "the quorum of 6 is unreachable with 5 sub-sequences")
+	}
+}
+
+func TestQuorumNegative(t *testing.T) {
+	assert.Panics(t, func() {
+		PhaseOf(succeed).AndJust(succeed).Quorum(-1)
+	})
+}
@@ -0,0 +1,375 @@
+package sequence
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// The state of a phase or sequence in a run.
+type State int
+
+const (
+	// The element hasn't started yet.
+	Pending State = iota
+
+	// The element is running.
+	Running
+
+	// The element and its children finished without a failure.
+	Succeeded
+
+	// The element or one of its children failed, or the element
+	// was interrupted before all of its children ran.
+	Failed
+
+	// The element wasn't run because of an earlier failure,
+	// or because its condition was false.
+	Skipped
+
+	// The element succeeded, and was then undone by its compensation.
+	Compensated
+)
+
+func (s State) String() string {
+	switch s {
+	case Pending:
+		return "pending"
+	case Running:
+		return "running"
+	case Succeeded:
+		return "succeeded"
+	case Failed:
+		return "failed"
+	case Skipped:
+		return "skipped"
+	case Compensated:
+		return "compensated"
+	}
+	return "unknown"
+}
+
+// The kind of element described by a Snapshot.
+type Kind int
+
+const (
+	KindSequence Kind = iota
+	KindPhase
+	KindDAG
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindSequence:
+		return "sequence"
+	case KindPhase:
+		return "phase"
+	case KindDAG:
+		return "dag"
+	}
+	return "unknown"
+}
+
+// A Snapshot describes the progress of a phase or sequence,
+// and of its children, at the time the snapshot was taken.
+type Snapshot struct {
+	Kind Kind
+	Name string
+	Path string
+	State State
+
+	// When the element started and finished running,
+	// or zero values if it hasn't.
+	Start time.Time
+	End time.Time
+
+	// The error which caused the element to fail, if it failed
+	// itself rather than because of one of its children.
+	Err error
+
+	// The number of iterations of a looping phase which have
+	// started, or 0 if the element doesn't loop. The rest of the
+	// snapshot describes the latest iteration.
+	Iterations int
+
+	// The paths of the elements which must succeed before the
+	// element starts, if it is a node of a DAG.
+	Deps []string
+
+	Children []Snapshot
+}
+
+// Implemented by RunAllers which can describe their progress.
+type Snapshotter interface {
+	Snapshot() Snapshot
+}
+
+// A record of the progress of a phase or sequence during a run,
+// used to report its state and to undo the effects of its
+// completed functions after a failure.
+type node struct {
+	sync.Mutex
+
+	kind Kind
+
+	// The name of the element, or "" if it has none.
+	name string
+
+	// The position of the element in the tree, such as "1/2/1"
+	// for the first phase of the second sequence of the first phase.
+	// See `UnitError`.
+	path string
+
+	// Whether the children ran, and are compensated, concurrently.
+	concurrent bool
+
+	state State
+	start time.Time
+	end time.Time
+	err error
+
+	// Whether a failure of the element doesn't fail its parent.
+	tolerated bool
+
+	// Whether the element was skipped because its condition was
+	// false, rather than interrupted.
+	bypassed bool
+
+	// The number of iterations of a looping element which have started.
+	iterations int
+
+	// The paths of the DAG nodes which the element depends on.
+	deps []string
+
+	// The compensation of the element's own function,
+	// set once that function has completed successfully.
+	undo func(context.Context) error
+
+	children []*node
+}
+
+// Implemented by elements which know the shape of their subtree
+// before they run.
+type planner interface {
+	plan(path string) *node
+}
+
+// Returns
+// a pending node for `r`, at `path`, with pending nodes for
+// each of the elements it contains if they are known.
+func planOf(r runAller, path string) *node {
+	if p, ok := r.(planner); ok {
+		return p.plan(path)
+	}
+	return &node{name: nameOf(r), path: path}
+}
+
+func (seq sequence) plan(path string) *node {
+	n := &node{kind: KindSequence, name: seq.name, path: path}
+	for i, ph := range seq.phases {
+		n.children = append(n.children, planOf(ph, childPath(path, i, nameOf(ph))))
+	}
+	return n
+}
+
+func (ph phase) plan(path string) *node {
+	n := &node{kind: KindPhase, name: ph.name, path: path, concurrent: true}
+	for i, seq := range ph.sequences {
+		n.children = append(n.children, planOf(seq, childPath(path, i, nameOf(seq))))
+	}
+	return n
+}
+
+type nodeKey struct{}
+
+// Returns
+// a copy of `ctx` which carries `n`, the node of the element being run.
+func withNode(ctx context.Context, n *node) context.Context {
+	return context.WithValue(ctx, nodeKey{}, n)
+}
+
+// Returns
+// the node carried by `ctx`, or a detached node if there is none.
+func nodeFrom(ctx context.Context) *node {
+	if n, ok := ctx.Value(nodeKey{}).(*node); ok {
+		return n
+	}
+	return new(node)
+}
+
+// Returns
+// the node of `r`, the `i`th child of the node's element counting
+// from 0, adding a pending node for it if it wasn't planned.
+func (n *node) child(i int, r runAller) *node {
+	n.Lock()
+	defer n.Unlock()
+	if i < len(n.children) {
+		return n.children[i]
+	}
+	c := planOf(r, childPath(n.path, i, nameOf(r)))
+	n.children = append(n.children, c)
+	return c
+}
+
+// Records that the element has started running.
+func (n *node) begin() {
+	n.Lock()
+	defer n.Unlock()
+	n.state = Running
+	n.start = time.Now()
+}
+
+// Records that the element wasn't run.
+func (n *node) skip() {
+	n.Lock()
+	defer n.Unlock()
+	n.state = Skipped
+}
+
+// Records that another iteration of a looping element has started.
+func (n *node) iterate() {
+	n.Lock()
+	defer n.Unlock()
+	n.iterations += 1
+}
+
+// Records that the element wasn't run because its condition was false.
+func (n *node) bypass() {
+	n.Lock()
+	defer n.Unlock()
+	n.state = Skipped
+	n.bypassed = true
+}
+
+// Records `err`, if it isn't nil, as the cause of the element's
+// failure.
+//
+// If `tolerated`, a failure of the element doesn't fail its parent.
+func (n *node) fail(err error, tolerated bool) {
+	n.Lock()
+	defer n.Unlock()
+	if err != nil {
+		n.err = err
+	}
+	n.tolerated = tolerated
+}
+
+// Records that the element has finished running, and decides
+// whether it succeeded.
+//
+// The element failed if a failure was recorded with `fail`, if it
+// was interrupted before all of its children ran, except those whose
+// conditions were false, or if one of its
+// children failed and `tolerant` is false. If it was interrupted,
+// the cause of the cancellation of `ctx` is recorded as its error.
+func (n *node) finish(ctx context.Context, tolerant bool) {
+	n.Lock()
+	defer n.Unlock()
+	n.end = time.Now()
+
+	nSkipped, childFailed := 0, false
+	for _, c := range n.children {
+		c.Lock()
+		switch c.state {
+		case Pending, Skipped:
+			if !c.bypassed {
+				nSkipped += 1
+			}
+		case Failed:
+			childFailed = childFailed || !c.tolerated
+		}
+		c.Unlock()
+	}
+
+	switch {
+	case n.err != nil || (childFailed && !tolerant):
+		n.state = Failed
+	case nSkipped > 0 && nSkipped == len(n.children):
+		n.state = Skipped
+	case nSkipped > 0:
+		n.state = Failed
+		if ctx.Err() != nil {
+			n.err = context.Cause(ctx)
+		}
+	default:
+		n.state = Succeeded
+	}
+}
+
+// Returns
+// the error which caused the element to fail, or nil.
+func (n *node) failure() error {
+	n.Lock()
+	defer n.Unlock()
+	return n.err
+}
+
+// Returns
+// the state of the element;
+// the error which caused it to fail, or nil.
+func (n *node) outcome() (State, error) {
+	n.Lock()
+	defer n.Unlock()
+	return n.state, n.err
+}
+
+// Records that the element failed with `err`
+// after it had finished running.
+func (n *node) override(err error) {
+	n.Lock()
+	defer n.Unlock()
+	n.state, n.err, n.tolerated = Failed, err, false
+}
+
+// Marks the elements of the node's subtree which haven't started
+// as skipped.
+func (n *node) skipPending() {
+	n.Lock()
+	defer n.Unlock()
+	if n.state == Pending {
+		n.state = Skipped
+	}
+	for _, c := range n.children {
+		c.skipPending()
+	}
+}
+
+// Returns
+// a snapshot of the progress of the node's subtree.
+func (n *node) snapshot() Snapshot {
+	n.Lock()
+	defer n.Unlock()
+	s := Snapshot{
+		Kind: n.kind,
+		Name: n.name,
+		Path: n.path,
+		State: n.state,
+		Start: n.start,
+		End: n.end,
+		Err: n.err,
+		Iterations: n.iterations,
+		Deps: n.deps,
+	}
+	for _, c := range n.children {
+		s.Children = append(s.Children, c.snapshot())
+	}
+	return s
+}
+
+// The tree of the latest run of a Sequence.
+type progress struct {
+	sync.Mutex
+	root *node
+}
+
+func (p *progress) set(root *node) {
+	p.Lock()
+	defer p.Unlock()
+	p.root = root
+}
+
+func (p *progress) get() *node {
+	p.Lock()
+	defer p.Unlock()
+	return p.root
+}
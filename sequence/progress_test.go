@@ -0,0 +1,117 @@
+package sequence
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+// Returns
+// the states of the nodes of `s`, by path.
+func states(s Snapshot) map[string]State {
+	m := map[string]State{s.Path: s.State}
+	for _, c := range s.Children {
+		for path, state := range states(c) {
+			m[path] = state
+		}
+	}
+	return m
+}
+
+func TestSnapshotBeforeRun(t *testing.T) {
+	seq := PhaseOf(succeed).AndJust(succeed).Name("A").End()
+
+	s := seq.Snapshot()
+
+	assert.Equal(t, KindSequence, s.Kind)
+	if assert.Len(t, s.Children, 1) {
+		assert.Equal(t, KindPhase, s.Children[0].Kind)
+		assert.Equal(t, "A", s.Children[0].Name)
+	}
+	assert.Equal(t, map[string]State{
+		"": Pending, "A": Pending, "A/1": Pending, "A/1/1": Pending,
+	}, states(s))
+}
+
+func TestSnapshotWhileRunning(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	seq := SequenceOf(PhaseOf(succeed).AndJust(func() error {
+		close(started)
+		<-release
+		return nil
+	})).ThenJust(succeed).End()
+
+	done := make(chan struct{})
+	go func() {
+		seq.RunAll(status.New())
+		close(done)
+	}()
+	<-started
+
+	assert.Equal(t, map[string]State{
+		"": Running, "1": Running, "1/1": Running, "1/1/1": Running,
+		"2": Pending,
+	}, states(seq.Snapshot()))
+
+	close(release)
+	<-done
+	s := seq.Snapshot()
+	assert.Equal(t, map[string]State{
+		"": Succeeded, "1": Succeeded, "1/1": Succeeded, "1/1/1": Succeeded,
+		"2": Succeeded,
+	}, states(s))
+	assert.False(t, s.Start.IsZero())
+	assert.False(t, s.End.Before(s.Start))
+}
+
+func TestSnapshotAfterFailure(t *testing.T) {
+	failure := errors.New("A failure")
+	seq := SequenceOf(
+		PhaseOf(succeed).Compensate(succeed),
+	).Then(
+		PhaseOf(func() error {
+			return failure
+		}),
+	).ThenJust(succeed).End()
+
+	seq.RunAll(status.New())
+
+	s := seq.Snapshot()
+	assert.Equal(t, map[string]State{
+		"": Failed, "1": Compensated, "2": Failed, "3": Skipped,
+	}, states(s))
+	assert.Equal(t, &UnitError{"2", failure}, s.Children[1].Err)
+	assert.Nil(t, s.Err)
+}
+
+func TestSnapshotTolerated(t *testing.T) {
+	failure := errors.New("A failure")
+	seq := PhaseOf(succeed).AndJust(func() error {
+		return failure
+	}).Policy(Isolate).End()
+
+	seq.RunAll(status.New())
+
+	assert.Equal(t, map[string]State{
+		"": Succeeded, "1": Succeeded, "1/1": Failed, "1/1/1": Failed,
+	}, states(seq.Snapshot()))
+}
+
+func TestSnapshotTimes(t *testing.T) {
+	seq := FirstJust(func() error {
+		time.Sleep(milliDuration)
+		return nil
+	}).End()
+
+	start := time.Now()
+	seq.RunAll(status.New())
+
+	s := seq.Snapshot().Children[0]
+	assert.WithinDuration(t, start, s.Start, milliDuration)
+	assert.True(t, s.End.Sub(s.Start) >= milliDuration)
+}
@@ -0,0 +1,97 @@
+package sequence
+
+import (
+	"time"
+)
+
+// A Record is a single piece of output from a sequence.
+type Record struct {
+	// When the output was produced.
+	Time time.Time
+
+	// The path of the unit which produced the output,
+	// or "" if it wasn't produced by a unit.
+	Path string
+
+	Stream Stream
+	Severity Severity
+
+	// The output itself, without a trailing newline.
+	Text string
+}
+
+// String formats the record as it is reported by the string API:
+// the text, prefixed with the unit's path if it has one.
+func (r Record) String() string {
+	if r.Path == "" {
+		return r.Text
+	}
+	return r.Path + ": " + r.Text
+}
+
+// The stream through which a record was produced.
+type Stream int
+
+const (
+	// Output reported by the sequence or by a unit's Emitter.
+	StreamInfo Stream = iota
+
+	// Output written by a process to its standard output.
+	StreamStdout
+
+	// Output written by a process to its standard error.
+	StreamStderr
+)
+
+func (s Stream) String() string {
+	switch s {
+	case StreamInfo:
+		return "info"
+	case StreamStdout:
+		return "stdout"
+	case StreamStderr:
+		return "stderr"
+	}
+	return "unknown"
+}
+
+// The importance of a record.
+type Severity int
+
+const (
+	SeverityDebug Severity = iota - 1
+	SeverityInfo // The default severity.
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityDebug:
+		return "debug"
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Adapts a channel of records to a channel of their strings.
+//
+// The returned channel is closed once `in` is closed.
+//
+// Returns
+// the channel of strings.
+func Strings(in <-chan Record) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for r := range in {
+			out <- r.String()
+		}
+	}()
+	return out
+}
@@ -0,0 +1,138 @@
+package sequence
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/nedp/command/status"
+)
+
+// A policy for retrying a phase's main function when it fails.
+//
+// The zero value never retries.
+type RetryPolicy struct {
+	// The total number of attempts, including the first.
+	// Values below 2 mean that there are no retries.
+	MaxAttempts int
+
+	// The delay before each retry; nil for no delay.
+	Backoff Backoff
+
+	// Whether an error may be retried; nil to retry every error.
+	Retryable func(error) bool
+}
+
+// A Backoff calculates the delay before the `retry`th retry,
+// counting from 1.
+type Backoff func(retry int) time.Duration
+
+// Returns
+// a backoff which always waits for `d`.
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// Returns
+// a backoff which waits for `base`, doubling the delay
+// with each retry, up to a maximum of `max`.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && d < max; i += 1 {
+			d *= 2
+		}
+		if d > max {
+			return max
+		}
+		return d
+	}
+}
+
+// Returns
+// a backoff which randomly varies the delays of `b` by up to
+// `fraction` of each delay in either direction.
+func WithJitter(b Backoff, fraction float64) Backoff {
+	return func(retry int) time.Duration {
+		d := b(retry)
+		jitter := (2*rand.Float64() - 1) * fraction * float64(d)
+		return d + time.Duration(jitter)
+	}
+}
+
+// Whether another attempt should follow the failed `attempt`.
+func (rp RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= rp.MaxAttempts {
+		return false
+	}
+	return rp.Retryable == nil || rp.Retryable(err)
+}
+
+// The delay before retrying after the failed `attempt`.
+func (rp RetryPolicy) delay(attempt int) time.Duration {
+	if rp.Backoff == nil {
+		return 0
+	}
+	return rp.Backoff(attempt)
+}
+
+// Calls `fn` until it succeeds or the policy stops retrying.
+//
+// Each failed attempt is reported to the output channel.
+// Between attempts, it sleeps for the policy's backoff and then
+// waits while the status is paused.
+//
+// Returns
+// nil if an attempt succeeded;
+// the last attempt's error, noting the number of attempts, otherwise;
+// `ctx`'s error if it is cancelled between attempts.
+func (rp RetryPolicy) call(ctx context.Context, stat status.Interface,
+	fn func(context.Context) error) error {
+	for attempt := 1; ; attempt += 1 {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || !rp.shouldRetry(attempt, err) {
+			if attempt > 1 {
+				err = fmt.Errorf("after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		d := rp.delay(attempt)
+		emit(ctx, Record{
+			Severity: SeverityWarning,
+			Text: fmt.Sprintf("attempt %d of %d failed: %v; retrying in %v",
+				attempt, rp.MaxAttempts, err, d),
+		})
+		if err := sleep(ctx, stat, d); err != nil {
+			return err
+		}
+	}
+}
+
+// Sleeps for `d`, then waits while the status is paused.
+//
+// Returns
+// nil when done;
+// `ctx`'s error if it is cancelled first.
+func sleep(ctx context.Context, stat status.Interface, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-stat.Unpaused():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,134 @@
+package sequence
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(milliDuration, 5*milliDuration)
+	assert.Equal(t, milliDuration, backoff(1))
+	assert.Equal(t, 2*milliDuration, backoff(2))
+	assert.Equal(t, 4*milliDuration, backoff(3))
+	assert.Equal(t, 5*milliDuration, backoff(4))
+	assert.Equal(t, 5*milliDuration, backoff(100))
+}
+
+func TestWithJitter(t *testing.T) {
+	backoff := WithJitter(ConstantBackoff(tinyDuration), 0.1)
+	for i := 1; i < nSequences; i += 1 {
+		assert.InEpsilon(t, int(tinyDuration), int(backoff(i)), 0.1)
+	}
+}
+
+func TestRetrySuccess(t *testing.T) {
+	nAttempts := 0
+	seq := PhaseOf(func() error {
+		nAttempts += 1
+		if nAttempts < 3 {
+			return errors.New("A failure")
+		}
+		return nil
+	}).Retry(RetryPolicy{
+		MaxAttempts: 3,
+		Backoff: ConstantBackoff(milliDuration),
+	}).End()
+
+	out := collect(seq)
+	stat := seq.RunAll(status.New())
+
+	assert.Nil(t, stat.Err())
+	assert.Equal(t, 3, nAttempts)
+	assert.Equal(t, []string{
+		"1: attempt 1 of 3 failed: A failure; retrying in 10ms",
+		"1: attempt 2 of 3 failed: A failure; retrying in 10ms",
+	}, <-out)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	failure := errors.New("A failure")
+	nAttempts := 0
+	seq := PhaseOf(func() error {
+		nAttempts += 1
+		return failure
+	}).Retry(RetryPolicy{MaxAttempts: 3}).End()
+
+	collect(seq)
+	stat := seq.RunAll(status.New())
+
+	assert.Equal(t, 3, nAttempts)
+	assert.ErrorIs(t, stat.Err(), failure)
+	assert.EqualError(t, stat.Err(), "1: after 3 attempts: A failure")
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	failure := errors.New("A failure")
+	nAttempts := 0
+	seq := PhaseOf(func() error {
+		nAttempts += 1
+		return failure
+	}).Retry(RetryPolicy{
+		MaxAttempts: 3,
+		Retryable: func(err error) bool { return err != failure },
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.Equal(t, 1, nAttempts)
+	assert.Equal(t, &UnitError{"1", failure}, stat.Err())
+}
+
+func TestRetryWaitsWhilePaused(t *testing.T) {
+	stat := status.New()
+	nAttempts := 0
+	seq := PhaseOf(func() error {
+		nAttempts += 1
+		if nAttempts == 1 {
+			stat.Pause()
+			return errors.New("A failure")
+		}
+		return nil
+	}).Retry(RetryPolicy{MaxAttempts: 2}).End()
+	collect(seq)
+
+	go func() {
+		time.Sleep(tinyDuration)
+		stat.Cont()
+	}()
+	start := time.Now()
+	seq.RunAll(stat)
+
+	assert.InEpsilon(t, int(tinyDuration), int(time.Since(start)), 0.2,
+		"The retry didn't wait for the status to be continued")
+	assert.Equal(t, 2, nAttempts)
+}
+
+func TestRetryStoppedWhileWaiting(t *testing.T) {
+	stat := status.New()
+	failure := errors.New("A failure")
+	nAttempts := 0
+	seq := PhaseOf(func() error {
+		nAttempts += 1
+		return failure
+	}).Retry(RetryPolicy{
+		MaxAttempts: 2,
+		Backoff: ConstantBackoff(shortDuration),
+	}).End()
+	collect(seq)
+
+	go func() {
+		time.Sleep(milliDuration)
+		stat.Fail()
+	}()
+	start := time.Now()
+	seq.RunAll(stat)
+
+	assert.WithinDuration(t, start, time.Now(), tinyDuration,
+		"The failure didn't interrupt the backoff")
+	assert.Equal(t, 1, nAttempts)
+}
@@ -7,17 +7,76 @@ function arguments.
 The functions are then run with a caller supplied status object
 used to track the status of the computation.
 If any function returns an error value, a failure is recorded in
-the status, any already-running units of computation are
-completed, and no more are run.
+the status along with the error, any already-running units of
+computation are completed, and no more are run.
+The recorded errors are available from the status' Err method.
+
+Functions may be given compensating functions, which undo their
+effects. If the computation fails, the compensations of the
+functions which completed are run in the reverse order, with
+those of concurrent functions run concurrently.
 
 The status object may be used by the caller to pause the sequence,
 continue a paused sequence, or trigger an early failure.
 In any of these cases, already-running functions will be completed,
 but no new functions in the sequence will be called.
+
+This failure model may be changed for a phase or sequence by
+setting its FailurePolicy, so that errors are recorded without
+failing, or so that failures are isolated from the rest of the
+computation. A phase may also require a quorum of its sub-sequences
+to succeed, isolating them from eachother.
+
+Functions added with the Ctx variants of the builder methods are
+passed the status' context, which is cancelled when a failure is
+recorded, so that they may return early instead of being completed.
+Units added with the Unit variants are also passed an Emitter,
+through which they report output attributed to their position in
+the sequence. The sequence owns its output channel, and closes it
+when each run finishes. Output is reported as Records, which carry
+the time, source unit, stream and severity of each piece of output;
+OutputChannel adapts them to strings.
+
+Phases and sequences may be named. Each element is identified by a
+path made from the names, or otherwise the positions, of its
+enclosing phases and sequences, which attributes its errors and
+output. The progress of each element during a run is available from
+the sequence's Snapshot method. DOT and Mermaid render a snapshot as a
+diagram, annotated with the states of its elements if the snapshot
+is of a run, such as one taken from a running Command.
+
+Behaviour which applies to many units, such as logging or timing,
+may be registered once as Middleware on a phase, a sequence, or a
+run, and wraps every unit within it.
+
+A phase built with ForEach runs a sub-sequence for each item of a
+collection which is only known when the phase runs. Units may pass
+values to later phases of the same run through Results, which
+collect many values, or through Values, which are produced once and
+may be awaited by concurrent units.
+
+Phases added with ThenIf, ElseIf and Else, and sub-sequences added
+with AndIf, run only if their conditions hold when they are reached;
+otherwise they are recorded as skipped without failing the run.
+Phases added with ThenLoop and its shortcuts run repeatedly, such as
+to poll until a check passes, pausing and stopping between iterations
+as the status requires.
+
+Where phases would make work wait for unrelated work, a DAGBuilder
+builds a graph of named sequences which each start as soon as their
+dependencies succeed. Build rejects graphs whose dependencies form a
+cycle, and returns a Sequence which runs like any other.
+
+A dry run, requested with RunOptions, lists the plan of a sequence
+in its output without running its units, using the descriptions set
+with PhaseBuilder.Describe.
 */
 package sequence
 
 import (
+	"context"
+	"time"
+
 	"github.com/nedp/command/status"
 )
 
@@ -30,17 +89,64 @@ type RunAller interface {
 	OutputChannel() <-chan string
 }
 
+// Implemented by RunAllers which report their output as Records.
+// Records is used instead of OutputChannel by the readers of
+// such RunAllers.
+type RecordRunAller interface {
+	RunAller
+	Records() <-chan Record
+}
+
+// Options for a run of a sequence, set by the caller of RunAllWith.
+type RunOptions struct {
+	// Wraps every unit in the sequence, outside of the middleware
+	// registered with the builders.
+	Middleware []Middleware
+
+	// Limits the units which run at once, or nil for no limit.
+	Workers *Workers
+
+	// Whether to list the plan of the run instead of running it.
+	//
+	// A dry run walks the sequence in the same order, and with the
+	// same concurrency, as a real run; but each main function is
+	// replaced by its description (see `PhaseBuilder.Describe`).
+	// Hooks, middleware and compensations aren't run; every
+	// conditional element is listed, and each loop is listed once.
+	DryRun bool
+}
+
+// Implemented by RunAllers which accept options for each run.
+type OptionRunAller interface {
+	RunAller
+	RunAllWith(status.Interface, RunOptions) status.Interface
+}
+
 // An object containing a series of computations to
 // be performed sequentially.
 type Sequence struct {
 	isRunning chan bool // buffered
 	sequence
 
-	output <-chan string
+	out *outlet
+	progress *progress
 }
 
 type sequence struct {
+	name string
 	phases []runAller
+
+	// The time limits of the sequence; zero values for no limit.
+	deadline time.Time
+	timeout time.Duration
+
+	policy FailurePolicy
+
+	hooks Hooks
+	middleware []Middleware
+
+	// Decides whether the sequence runs, or nil if it always does.
+	cond condition
 }
 
 // Runs all computations in the sequence.
@@ -48,29 +154,152 @@ type sequence struct {
 // If the sequence is already running concurrently, this function blocks
 // until the other run finishes.
 //
+// The output channel is closed when the run finishes. If nobody
+// has asked for it with Records or OutputChannel, the run's output
+// is dropped. See `Records`.
+//
 // Returns
-// `true`  if all computations ran successfully,
-// `false` if there was a failure.
+// the status, which records whether there was a failure.
 func (seq Sequence) RunAll(stat status.Interface) status.Interface {
+	return seq.RunAllWith(stat, RunOptions{})
+}
+
+// Like RunAll, but with the options `opts`.
+//
+// Returns
+// the status, which records whether there was a failure.
+func (seq Sequence) RunAllWith(stat status.Interface, opts RunOptions) status.Interface {
 	seq.isRunning <- true
 	defer func(){
 		<-seq.isRunning
 	}()
-	return seq.runAll(stat)
+	if seq.out != nil {
+		seq.out.start()
+		defer seq.out.close()
+	}
+
+	root := seq.plan(seq.name)
+	if seq.progress != nil {
+		seq.progress.set(root)
+	}
+	ctx := withOutlet(stat.Context(), seq.out)
+	ctx = withNode(withStatus(ctx, stat), root)
+	ctx = withMiddleware(ctx, opts.Middleware)
+	ctx = withWorkers(ctx, opts.Workers)
+	ctx = withStore(ctx)
+	ctx = withDryRun(ctx, opts.DryRun)
+	stat = seq.guard(ctx, stat)
+
+	// Undo the effects of completed functions after a failure.
+	if root.hasUndo() && stat.HasFailed() {
+		root.compensate(context.WithoutCancel(ctx), stat)
+	}
+	root.skipPending()
+	return stat
 }
 
-func (seq sequence) runAll(stat status.Interface) status.Interface {
+// Runs the sequence, recording a panic which escapes it as a failure.
+func (seq sequence) guard(ctx context.Context, stat status.Interface) (result status.Interface) {
+	result = stat
+	defer recoverInto(stat)
+	return seq.runAll(ctx, stat)
+}
+
+func (seq sequence) runAll(ctx context.Context, stat status.Interface) status.Interface {
+	if !seq.cond.allows(ctx, stat) {
+		return stat
+	}
+	return seq.hooks.around(ctx, stat, seq.runSequence)
+}
+
+func (seq sequence) runSequence(ctx context.Context, stat status.Interface) status.Interface {
+	ctx = withMiddleware(ctx, seq.middleware)
+	// Each run of the sequence decides its conditional phases afresh.
+	ctx = withDecisions(ctx)
+	n := nodeFrom(ctx)
+	n.begin()
+	defer n.finish(ctx, seq.policy == ContinueOnError)
+
+	if seq.policy != Isolate {
+		return seq.runTimed(ctx, stat)
+	}
+	// Report the errors of the isolated sequence without failing.
+	_, err := isolate(ctx, stat, seq.runTimed)
+	stat.Report(err)
+	n.fail(nil, true)
+	return stat
+}
+
+func (seq sequence) runTimed(ctx context.Context, stat status.Interface) status.Interface {
+	deadline, ok := seq.deadlineFrom(time.Now())
+	if !ok {
+		return seq.runPhases(ctx, stat)
+	}
+
+	timeout := &TimeoutError{deadline}
+	ctx, cancel := context.WithDeadlineCause(ctx, deadline, timeout)
+	defer cancel()
+
+	// Fail as soon as the deadline passes, but wait for the phases
+	// to return, so that nothing is still running when the sequence
+	// finishes or is compensated.
+	n := nodeFrom(ctx)
+	done := make(chan struct{})
+	watched := make(chan struct{})
+	go func(stat status.Interface) {
+		defer close(watched)
+		select {
+		case <-ctx.Done():
+			if context.Cause(ctx) == error(timeout) {
+				n.fail(timeout, false)
+				_ = stat.FailWith(timeout) // Don't care if a failure already occured.
+			}
+		case <-done:
+		}
+	}(stat)
+	result := seq.runPhases(ctx, stat)
+	close(done)
+	<-watched
+	return result
+}
+
+func (seq sequence) runPhases(ctx context.Context, stat status.Interface) status.Interface {
+	n := nodeFrom(ctx)
 	// Run each phase with the same status.
-	for _, phase := range seq.phases {
+	for i, phase := range seq.phases {
+		ctx := withNode(ctx, n.child(i, phase))
+		if seq.policy == ContinueOnError {
+			// Report the errors of the isolated phase without failing.
+			_, err := isolate(ctx, stat, phase.runAll)
+			stat.Report(err)
+		} else {
+			stat = phase.runAll(ctx, stat)
+		}
 		// If there is a failure, stop running phases.
-		stat = phase.runAll(stat)
 		if stat.HasFailed() {
+			for j := i + 1; j < len(seq.phases); j += 1 {
+				n.child(j, seq.phases[j]).skip()
+			}
 			break
 		}
 	}
 	return stat
 }
 
+// Returns
+// the earliest of the sequence's time limits, when starting at `now`;
+// whether the sequence has a time limit.
+func (seq sequence) deadlineFrom(now time.Time) (time.Time, bool) {
+	deadline := seq.deadline
+	if seq.timeout > 0 {
+		end := now.Add(seq.timeout)
+		if deadline.IsZero() || end.Before(deadline) {
+			deadline = end
+		}
+	}
+	return deadline, !deadline.IsZero()
+}
+
 // Returns
 // whether the sequence is currently being run in another goroutine.
 func (seq Sequence) IsRunning() bool {
@@ -85,8 +314,41 @@ func (seq Sequence) IsRunning() bool {
 }
 
 // Returns
-// the output channel of the sequence.
-// TODO untested
+// a snapshot of the progress of the current run, or of the last run
+// if the sequence isn't running; or a snapshot in which every element
+// is pending if the sequence hasn't run.
+//
+// Safe to call concurrently with RunAll.
+func (seq Sequence) Snapshot() Snapshot {
+	if seq.progress != nil {
+		if root := seq.progress.get(); root != nil {
+			return root.snapshot()
+		}
+	}
+	return seq.plan(seq.name).snapshot()
+}
+
+// Returns
+// the output channel of the current run, or of the next run if
+// the sequence isn't running.
+// The channel is closed when its run finishes.
+//
+// Output is only sent once Records, or OutputChannel, has been
+// called for the run; until then it is dropped. Once it has been
+// called, the channel MUST be read until it is closed, since units
+// block while their output isn't read.
+func (seq Sequence) Records() <-chan Record {
+	return seq.out.attach()
+}
+
+// Adapts the output channel of the current or next run to strings.
+// See `Records` and `Strings`.
+//
+// Only one of the channels returned by Records and OutputChannel
+// should be read from for each run.
+//
+// Returns
+// a channel of the formatted output of the run.
 func (seq Sequence) OutputChannel() <-chan string {
-	return seq.output
+	return Strings(seq.Records())
 }
@@ -1,6 +1,7 @@
 package sequence
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -80,7 +81,7 @@ func testRunAllSuccess(t *testing.T, shouldUsePublic bool, nPhases int, seq Sequ
 	if shouldUsePublic {
 		stat = seq.RunAll(stat).(*statusMock)
 	} else {
-		stat = seq.runAll(stat).(*statusMock)
+		stat = seq.runAll(context.Background(), stat).(*statusMock)
 	}
 
 	// Verify success.
@@ -130,7 +131,7 @@ func testRunAllFailure(t *testing.T, shouldUsePublic bool, nPhases int, seq Sequ
 	if shouldUsePublic {
 		stat = seq.RunAll(stat).(*statusMock)
 	} else {
-		stat = seq.runAll(stat).(*statusMock)
+		stat = seq.runAll(context.Background(), stat).(*statusMock)
 	}
 
 	// Verify that the failure was noticed.
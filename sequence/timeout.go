@@ -0,0 +1,66 @@
+package sequence
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TimeoutError is recorded as the cause of a failure when a phase's
+// main function, or a whole sequence, runs past its deadline.
+//
+// It matches `context.DeadlineExceeded` with `errors.Is`.
+type TimeoutError struct {
+	Deadline time.Time
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("deadline exceeded at %s",
+		e.Deadline.Format(time.RFC3339Nano))
+}
+
+// Timeout always returns true, to distinguish timeouts
+// in the manner of `net.Error`.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Runs `fn`, abandoning it if it hasn't returned by `deadline`.
+//
+// `fn` is passed a context which is cancelled at `deadline`,
+// but it isn't waited for after that, so that a function which
+// ignores its context can't block its caller.
+//
+// Returns
+// the result of `fn` if it returns in time;
+// a `*TimeoutError` if the deadline passes first;
+// the cause of the cancellation if `ctx` is cancelled first.
+func within(ctx context.Context, deadline time.Time, fn func(context.Context) error) error {
+	timeout := &TimeoutError{deadline}
+	ctx, cancel := context.WithDeadlineCause(ctx, deadline, timeout)
+	defer cancel()
+
+	// The function may be abandoned while it waits for a Value,
+	// so it counts as a goroutine of the run of its own.
+	s := storeFrom(ctx)
+	result := make(chan error, 1)
+	s.enter()
+	go func() {
+		defer s.leave()
+		result <- protect(fn)(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil && context.Cause(ctx) == error(timeout) {
+			return timeout
+		}
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
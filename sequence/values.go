@@ -0,0 +1,300 @@
+package sequence
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// The Results and Values produced by the units of a run, kept
+// separately for each run of a sequence.
+type store struct {
+	sync.Mutex
+	values map[interface{}]interface{}
+
+	// The goroutines of the run which may still produce values,
+	// and how many of them are waiting for a Value.
+	active, waiting int
+
+	// Closed, and replaced, once every active goroutine is waiting
+	// for a Value, since none of them can be produced any more.
+	stuck chan struct{}
+}
+
+type storeKey struct{}
+
+// Returns
+// a new, empty store, with `active` active goroutines.
+func newStore(active int) *store {
+	return &store{
+		values: make(map[interface{}]interface{}),
+		active: active,
+		stuck: make(chan struct{}),
+	}
+}
+
+// Returns
+// a copy of `ctx` which carries a new, empty store,
+// whose only active goroutine is the caller's.
+func withStore(ctx context.Context) context.Context {
+	return context.WithValue(ctx, storeKey{}, newStore(1))
+}
+
+// Returns
+// the store carried by `ctx`, or a detached store if there is none.
+// No goroutine is active in a detached store.
+func storeFrom(ctx context.Context) *store {
+	if s, ok := ctx.Value(storeKey{}).(*store); ok {
+		return s
+	}
+	return newStore(0)
+}
+
+// Records that a goroutine of the run has started, or has stopped
+// waiting for other goroutines of the run to finish.
+func (s *store) enter() {
+	s.Lock()
+	defer s.Unlock()
+	s.active += 1
+}
+
+// Records that a goroutine of the run has finished, or is waiting
+// for other goroutines of the run to finish.
+func (s *store) leave() {
+	s.Lock()
+	defer s.Unlock()
+	s.active -= 1
+	s.check()
+}
+
+// Records that a goroutine of the run is waiting for a Value.
+//
+// Returns
+// a channel which is closed if the value can no longer be produced.
+func (s *store) wait() <-chan struct{} {
+	s.Lock()
+	defer s.Unlock()
+	s.waiting += 1
+	stuck := s.stuck
+	s.check()
+	return stuck
+}
+
+// Records that a goroutine has stopped waiting for a Value.
+func (s *store) unwait() {
+	s.Lock()
+	defer s.Unlock()
+	s.waiting -= 1
+}
+
+// Calls `wait`, which waits for other goroutines of the run to
+// finish, without counting the caller as active meanwhile.
+func (s *store) idle(wait func()) {
+	s.leave()
+	defer s.enter()
+	wait()
+}
+
+// Releases the waiting goroutines if no goroutine could produce
+// what they are waiting for. Expects the store to be locked.
+func (s *store) check() {
+	if s.waiting > 0 && s.waiting >= s.active {
+		close(s.stuck)
+		s.stuck = make(chan struct{})
+	}
+}
+
+// Results collects values of type T produced by the units of a run,
+// such as those of the sub-sequences of a ForEach phase, so that
+// later phases may use them.
+//
+// The values are kept separately for each run, so the methods of
+// Results must be passed the context of a unit, hook or item producer
+// in the run. Any number of units may add values concurrently.
+type Results[T any] struct {
+	// Identifies the results in the store of each run.
+	key *int
+}
+
+// Creates a new, empty collection of results.
+//
+// Returns
+// the collection.
+func NewResults[T any]() Results[T] {
+	return Results[T]{new(int)}
+}
+
+// Adds `v` to the results of the run whose context is `ctx`.
+func (r Results[T]) Add(ctx context.Context, v T) {
+	s := storeFrom(ctx)
+	s.Lock()
+	defer s.Unlock()
+	vs, _ := s.values[r.key].([]T)
+	s.values[r.key] = append(vs, v)
+}
+
+// Returns
+// a copy of the results added so far in the run whose context is `ctx`,
+// in the order in which they were added.
+func (r Results[T]) All(ctx context.Context) []T {
+	s := storeFrom(ctx)
+	s.Lock()
+	defer s.Unlock()
+	vs, _ := s.values[r.key].([]T)
+	return append([]T(nil), vs...)
+}
+
+// ValueError is returned when a Value is read before it was produced
+// in a run, or produced more than once in a run.
+type ValueError struct {
+	Name string
+
+	// Whether the value had already been produced, rather than
+	// not having been produced.
+	Produced bool
+}
+
+func (e *ValueError) Error() string {
+	if e.Produced {
+		return fmt.Sprintf("value %q was already produced", e.Name)
+	}
+	return fmt.Sprintf("value %q was never produced", e.Name)
+}
+
+// Value passes a single value of type T from the unit which produces
+// it to the units which consume it, such as those of later phases, or
+// the main function of the phase whose sub-sequence produces it.
+//
+// Each run has its own copy of the value, so the methods of Value
+// must be passed the context of a unit, hook or item producer in the
+// run. The value is owned by the one unit which calls Set; any number
+// of units may read it, concurrently, once it is set.
+type Value[T any] struct {
+	name string
+
+	// Identifies the value in the store of each run.
+	key *int
+}
+
+// The state of a Value in a run.
+type slot struct {
+	// Closed once the value is set.
+	set chan struct{}
+	v interface{}
+}
+
+// Creates a new value, named `name` in errors.
+//
+// Returns
+// the value.
+func NewValue[T any](name string) Value[T] {
+	return Value[T]{name, new(int)}
+}
+
+// Returns
+// the slot of the value with `key`, which is added if there was none.
+func (s *store) slot(key *int) *slot {
+	s.Lock()
+	defer s.Unlock()
+	sl, ok := s.values[key].(*slot)
+	if !ok {
+		sl = &slot{set: make(chan struct{})}
+		s.values[key] = sl
+	}
+	return sl
+}
+
+// Returns
+// the value's name.
+func (v Value[T]) Name() string {
+	return v.name
+}
+
+// Produces `x` as the value in the run whose context is `ctx`,
+// releasing any units waiting for it with Await.
+//
+// Returns
+// a `*ValueError` if the value was already produced in the run.
+func (v Value[T]) Set(ctx context.Context, x T) error {
+	s := storeFrom(ctx)
+	sl := s.slot(v.key)
+	s.Lock()
+	defer s.Unlock()
+	select {
+	case <-sl.set:
+		return &ValueError{v.name, true}
+	default:
+	}
+	sl.v = x
+	close(sl.set)
+	return nil
+}
+
+// Reads the value in the run whose context is `ctx`,
+// without waiting for it to be produced.
+//
+// Returns
+// the value;
+// a `*ValueError` if it hasn't been produced in the run.
+func (v Value[T]) Get(ctx context.Context) (T, error) {
+	sl := storeFrom(ctx).slot(v.key)
+	select {
+	case <-sl.set:
+		return sl.v.(T), nil
+	default:
+		var zero T
+		return zero, &ValueError{v.name, false}
+	}
+}
+
+// Reads the value in the run whose context is `ctx`,
+// waiting for it to be produced by a concurrent unit.
+//
+// Await is only for values produced by units which run concurrently
+// with the caller, such as those of its sub-sequences or of sibling
+// sequences. If every unit of the run which is still running is
+// waiting for a value, none of them can be produced, so each of them
+// fails instead of waiting forever. A value which is set by anything
+// other than a unit, hook or item producer of the run may not be
+// waited for.
+//
+// While waiting, the unit doesn't count towards the run's limit
+// of Workers.
+//
+// Returns
+// the value;
+// a `*ValueError` if the value can no longer be produced;
+// the cause of the cancellation of `ctx`, if it is cancelled
+// before the value is produced.
+func (v Value[T]) Await(ctx context.Context) (T, error) {
+	s := storeFrom(ctx)
+	sl := s.slot(v.key)
+	select {
+	case <-sl.set:
+		return sl.v.(T), nil
+	default:
+	}
+
+	h := holdFrom(ctx)
+	h.free()
+	stuck := s.wait()
+	select {
+	case <-sl.set:
+	case <-stuck:
+	case <-ctx.Done():
+	}
+	s.unwait()
+
+	var zero T
+	select {
+	case <-sl.set:
+		if h.resume(ctx) {
+			return sl.v.(T), nil
+		}
+	default:
+		if ctx.Err() == nil && h.resume(ctx) {
+			return zero, &ValueError{v.name, false}
+		}
+	}
+	return zero, context.Cause(ctx)
+}
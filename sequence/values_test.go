@@ -0,0 +1,161 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nedp/command/status"
+)
+
+func TestValueBetweenPhases(t *testing.T) {
+	count := NewValue[int]("count")
+	var got int
+	seq := FirstJustCtx(func(ctx context.Context) error {
+		return count.Set(ctx, 3)
+	}).ThenJustCtx(func(ctx context.Context) (err error) {
+		got, err = count.Get(ctx)
+		return err
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, 3, got)
+}
+
+func TestValueNeverProduced(t *testing.T) {
+	count := NewValue[int]("count")
+	seq := FirstJust(succeed).ThenJustCtx(func(ctx context.Context) error {
+		_, err := count.Get(ctx)
+		return err
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.True(t, stat.HasFailed())
+	assert.Equal(t, &UnitError{"2", &ValueError{"count", false}}, stat.Err())
+	assert.EqualError(t, stat.Err(), `2: value "count" was never produced`)
+}
+
+func TestValueProducedTwice(t *testing.T) {
+	count := NewValue[int]("count")
+	var errs []error
+	seq := FirstJustCtx(func(ctx context.Context) error {
+		errs = append(errs, count.Set(ctx, 1), count.Set(ctx, 2))
+		return nil
+	}).End()
+
+	seq.RunAll(status.New())
+
+	assert.Equal(t, []error{nil, &ValueError{"count", true}}, errs)
+}
+
+func TestValuePerRun(t *testing.T) {
+	count := NewValue[int]("count")
+	seq := FirstJustCtx(func(ctx context.Context) error {
+		return count.Set(ctx, 1)
+	}).End()
+
+	assert.False(t, seq.RunAll(status.New()).HasFailed())
+	assert.False(t, seq.RunAll(status.New()).HasFailed())
+}
+
+func TestValueAwaitedByParent(t *testing.T) {
+	name := NewValue[string]("name")
+	var got string
+	seq := PhaseOfCtx(func(ctx context.Context) (err error) {
+		got, err = name.Await(ctx)
+		return err
+	}).AndJustCtx(func(ctx context.Context) error {
+		time.Sleep(milliDuration)
+		return name.Set(ctx, "A")
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, "A", got)
+}
+
+func TestValueAwaitedWithOneWorker(t *testing.T) {
+	name := NewValue[string]("name")
+	var got string
+	seq := PhaseOfCtx(func(ctx context.Context) (err error) {
+		got, err = name.Await(ctx)
+		return err
+	}).AndJustCtx(func(ctx context.Context) error {
+		time.Sleep(milliDuration)
+		return name.Set(ctx, "A")
+	}).End()
+
+	// The parent gives up its worker while it waits for the value.
+	stat := seq.RunAllWith(status.New(), RunOptions{Workers: NewWorkers(1)})
+
+	assert.False(t, stat.HasFailed())
+	assert.Equal(t, "A", got)
+}
+
+func TestValueAwaitCancelled(t *testing.T) {
+	failure := errors.New("A failure")
+	name := NewValue[string]("name")
+	var awaitErr error
+	seq := PhaseOfCtx(func(ctx context.Context) (err error) {
+		_, awaitErr = name.Await(ctx)
+		return nil
+	}).AndJust(func() error {
+		time.Sleep(milliDuration)
+		return failure
+	}).End()
+
+	stat := seq.RunAll(status.New())
+
+	assert.True(t, stat.HasFailed())
+	assert.ErrorIs(t, awaitErr, failure)
+}
+
+func TestValueAwaitedNeverProduced(t *testing.T) {
+	name := NewValue[string]("name")
+	seq := FirstJust(succeed).ThenJustCtx(func(ctx context.Context) error {
+		_, err := name.Await(ctx)
+		return err
+	}).End()
+
+	// Nothing else is running which could produce the value.
+	stat := seq.RunAll(status.New())
+
+	assert.Equal(t, &UnitError{"2", &ValueError{"name", false}}, stat.Err())
+}
+
+func TestValueAwaitedNotProducedConcurrently(t *testing.T) {
+	name := NewValue[string]("name")
+	other := NewValue[string]("other")
+	var awaitErr error
+	seq := PhaseOfCtx(func(ctx context.Context) (err error) {
+		_, awaitErr = name.Await(ctx)
+		return nil
+	}).AndJustCtx(func(ctx context.Context) error {
+		time.Sleep(milliDuration)
+		return nil
+	}).AndJustCtx(func(ctx context.Context) error {
+		// Waits for a value which nothing produces either.
+		_, err := other.Await(ctx)
+		return err
+	}).Policy(ContinueOnError).End()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		seq.RunAll(status.New())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(tinyDuration):
+		t.Fatal("The units waited for values which weren't being produced")
+	}
+	assert.Equal(t, &ValueError{"name", false}, awaitErr)
+}
@@ -0,0 +1,117 @@
+package status
+
+import (
+	"sync"
+	"time"
+)
+
+// The kind of lifecycle transition an Event describes.
+type EventKind int
+
+const (
+	EventCommandStarted EventKind = iota
+	EventCommandFinished
+	EventPhaseEntered
+	EventPhaseExited
+	EventUnitStarted
+	EventUnitFinished
+	EventUnitFailed
+	EventPaused
+	EventContinued
+	EventFailed
+	EventStopped
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventCommandStarted:
+		return "command started"
+	case EventCommandFinished:
+		return "command finished"
+	case EventPhaseEntered:
+		return "phase entered"
+	case EventPhaseExited:
+		return "phase exited"
+	case EventUnitStarted:
+		return "unit started"
+	case EventUnitFinished:
+		return "unit finished"
+	case EventUnitFailed:
+		return "unit failed"
+	case EventPaused:
+		return "paused"
+	case EventContinued:
+		return "continued"
+	case EventFailed:
+		return "failed"
+	case EventStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// An Event describes a lifecycle transition of a status,
+// or of the computation it tracks.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+
+	// The path of the phase or unit the event is about, if any.
+	Path string
+
+	// The error which caused a failure or finished a command, if any.
+	Err error
+}
+
+// The subscribers to a status' events, shared with its copies.
+type events struct {
+	sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// Subscribes to the status' events, and those of its copies.
+//
+// Delivery doesn't block: if the subscriber's buffer of `size`
+// events is full, further events are dropped until there's room.
+// A `size` of less than 1 is treated as 1.
+//
+// Returns
+// the channel of events;
+// a function which unsubscribes and closes the channel.
+func (s *Status) Subscribe(size int) (<-chan Event, func()) {
+	if size < 1 {
+		size = 1
+	}
+	ch := make(chan Event, size)
+
+	s.state.events.Lock()
+	defer s.state.events.Unlock()
+	s.state.events.subs[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.state.events.Lock()
+			defer s.state.events.Unlock()
+			delete(s.state.events.subs, ch)
+			close(ch)
+		})
+	}
+}
+
+// Delivers `e` to each subscriber which has room for it.
+//
+// The event's Time is set if it is zero.
+func (s *Status) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	s.state.events.Lock()
+	defer s.state.events.Unlock()
+	for ch := range s.state.events.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
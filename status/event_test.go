@@ -0,0 +1,50 @@
+package status
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+	status := New()
+	events, unsubscribe := status.Subscribe(8)
+
+	failure := errors.New("A failure")
+	status.Pause()
+	status.Pause()
+	status.BoundCopy().Cont()
+	status.IsolatedCopy().Publish(Event{Kind: EventUnitStarted, Path: "1"})
+	status.FailWith(failure)
+	status.FailWith(failure)
+	unsubscribe()
+	unsubscribe()
+
+	var kinds []EventKind
+	for e := range events {
+		assert.False(t, e.Time.IsZero(), "The event's time wasn't set")
+		kinds = append(kinds, e.Kind)
+		if e.Kind == EventFailed {
+			assert.Equal(t, failure, e.Err)
+		}
+	}
+	assert.Equal(t, []EventKind{
+		EventPaused, EventContinued, EventUnitStarted, EventFailed,
+	}, kinds)
+}
+
+func TestSubscribeDoesntBlock(t *testing.T) {
+	t.Parallel()
+	status := New()
+	slow, _ := status.Subscribe(1)
+	fast, _ := status.Subscribe(3)
+
+	for i := 0; i < 3; i += 1 {
+		status.Publish(Event{Kind: EventUnitStarted})
+	}
+
+	assert.Len(t, slow, 1)
+	assert.Len(t, fast, 3)
+}
@@ -6,16 +6,24 @@ Under the hood it combines and wraps functionality from the `sync` package.
 
 Supported actions are:
  * Pausing, continuing, and failing
+ * Recording the errors which caused a failure
+ * Reporting errors without failing
+ * Providing a context which is cancelled by a failure
+ * Publishing lifecycle events to any number of subscribers
  * Registering the beginning and end of concurrent tasks
  * Acquiring a read lock after waiting for readiness
  * Releasing the read lock
  * Creating a 'bound copy', which is a new object with:
     - a separate set of bound tasks
     - pause/continue/failure and read-lock state bound to the original's
+ * Creating an 'isolated copy', which is like a bound copy except that:
+    - a failure of the isolated copy doesn't fail the original
+    - a failure of the original still fails the isolated copy
 */
 package status
 
 import (
+	"context"
 	"errors"
 	"sync"
 )
@@ -28,14 +36,25 @@ type Interface interface {
 
 	Pause() (bool, error)
 	IsPaused() bool
+	Paused() <-chan struct{}
+	Unpaused() <-chan struct{}
 	Cont() (bool, error)
 	Fail() error
+	FailWith(error) error
 	HasFailed() bool
+	Report(error)
+	Err() error
+	Context() context.Context
+
+	Subscribe(int) (<-chan Event, func())
+	Publish(Event)
 
 	Add(int)
 	Done()
+	Wait()
 
 	BoundCopy() Interface
+	IsolatedCopy() Interface
 }
 
 // The underlying type for Interface objects returned by this package.
@@ -48,12 +67,41 @@ type Status struct {
 type state struct {
 	// rw is the same mutex as the contents of the sync.Cond member.
 	rw *sync.RWMutex
-	sync.Cond
+	*sync.Cond
+
+	*pause // Shared with isolated copies
+	*events // Shared with isolated copies
+	*scope // Not shared with isolated copies
+}
+
+type pause struct {
+	isPaused bool
+
+	// paused is closed whenever the status is paused.
+	paused chan struct{}
+
+	// unpaused is closed whenever the status isn't paused.
+	unpaused chan struct{}
+}
+
+// The failure state shared by a status and its bound copies.
+type scope struct {
+	// The scope of the status this was isolated from, or nil.
+	parent *scope
 
-	isPaused  bool
 	hasFailed bool
+	errs      []error
+
+	// ctx is cancelled, with the first recorded error as its cause,
+	// when a failure is recorded in this scope or its parent.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
 }
 
+// ErrFailed is reported by Err when a failure was recorded
+// without any error describing its cause.
+var ErrFailed = errors.New("a failure occured without a recorded cause")
+
 // Creates a new status.
 //
 // A new status object is unlocked, not paused, not waiting,
@@ -63,13 +111,17 @@ type state struct {
 //  The new status.
 func New() Interface {
 	rw := new(sync.RWMutex)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	unpaused := make(chan struct{})
+	close(unpaused)
 	s := &Status{
 		sync.WaitGroup{},
 		&state{
 			rw,
-			*sync.NewCond(rw.RLocker()),
-			false,
-			false,
+			sync.NewCond(rw.RLocker()),
+			&pause{false, make(chan struct{}), unpaused},
+			&events{subs: make(map[chan Event]struct{})},
+			&scope{nil, false, nil, ctx, cancel},
 		},
 	}
 	return s
@@ -96,6 +148,45 @@ func (s *Status) BoundCopy() Interface {
 	}
 }
 
+// Creates an 'isolated copy' of the receiver after acquiring a read lock.
+// The read lock will be released before returning.
+//
+// The isolated copy is like a bound copy, except that a failure
+// recorded by the isolated copy won't be recorded by the original.
+// A failure recorded by the original is still recorded by the
+// isolated copy, and cancels its context.
+//
+// Returns:
+//  The isolated copy
+func (s *Status) IsolatedCopy() Interface {
+	// RLock
+	s.state.L.Lock()
+	defer s.state.L.Unlock()
+
+	ctx, cancel := context.WithCancelCause(s.state.ctx)
+	return &Status{
+		sync.WaitGroup{},
+		&state{
+			s.state.rw,
+			s.state.Cond,
+			s.state.pause,
+			s.state.events,
+			&scope{s.state.scope, false, nil, ctx, cancel},
+		},
+	}
+}
+
+// Whether a failure has been recorded in the scope or its parents.
+// The caller must hold the lock.
+func (sc *scope) failed() bool {
+	for ; sc != nil; sc = sc.parent {
+		if sc.hasFailed {
+			return true
+		}
+	}
+	return false
+}
+
 // Wrapper function for `sync.RWMutex.RLock()`
 func (s *Status) RLock() {
 	s.state.L.Lock()
@@ -106,33 +197,37 @@ func (s *Status) RLock() {
 // 'Ready' means it is not paused, has not failed, and is not waiting.
 // RUnlock() should be called to relinquish the read-lock.
 //
+// The read lock is not held while waiting for bound tasks to finish,
+// so that those tasks may record a failure in the meantime.
+//
 // Returns:
 //   `true` if the RLock is acquired.
 //  `false` if a failure has occured.
 func (s *Status) ReadyRLock() bool {
 	// 1. RLock
 	s.state.L.Lock()
-
-	// 2. Check for an early exit during the RLock
-	if s.state.hasFailed {
+	for {
+		// 2. Check for an early exit during the RLock
+		if s.state.failed() {
+			s.state.L.Unlock()
+			return false
+		}
+		// 3. Wait until waitgroup is done outside the RLock
 		s.state.L.Unlock()
-		return false
-	}
-	// 3. Wait until waitgroup is done during the RLock
-	s.Wait()
-	for s.state.isPaused {
-		// 4. Wait until unpaused, acquiring L.Lock
-		s.state.Wait()
+		s.Wait()
+		s.state.L.Lock()
 
-		// 2. Check for an early exit during the RLock.
-		if s.state.hasFailed {
+		// 4. Recheck for failure, and confirm that we're unpaused.
+		if s.state.failed() {
 			s.state.L.Unlock()
 			return false
 		}
-		// 3. Wait until waitgroup is done during the RLock.
-		s.Wait()
-	} // 5. Reconfirm the unpaused + no failure in the loop condition.
-	return true
+		if !s.state.isPaused {
+			return true
+		}
+		// 5. Wait until unpaused, reacquiring L.Lock
+		s.state.Wait()
+	}
 }
 
 // Wrapper function for `sync.RWMutex.RUnlock()`
@@ -148,18 +243,110 @@ func (s *Status) RUnlock() {
 //     `nil` if no failure had yet been recorded.
 //  an error if a failure was already recorded.
 func (s *Status) Fail() error {
+	return s.FailWith(nil)
+}
+
+// Records a failure caused by `err`, cancelling the status' context.
+//
+// `err` is recorded even if a failure was already recorded,
+// so that every error from concurrent tasks is reported by Err.
+// The exceptions are `context.Canceled` after a failure, which is
+// only the consequence of the earlier failure, and errors which
+// were already recorded.
+// A nil `err` records a failure without a cause.
+//
+// This cannot be undone.
+//
+// Returns:
+//     `nil` if no failure had yet been recorded.
+//  an error if a failure was already recorded.
+func (s *Status) FailWith(err error) error {
 	// Write lock
 	s.state.rw.Lock()
 	defer s.state.rw.Unlock()
 
-	if s.state.hasFailed {
+	if s.state.failed() {
+		if err != nil && !errors.Is(err, context.Canceled) && !s.state.recorded(err) {
+			s.state.errs = append(s.state.errs, err)
+		}
+		s.state.scope.hasFailed = true
 		return errors.New("A failure already occured.")
 	}
-	s.state.hasFailed = true
+	if err != nil {
+		s.state.errs = append(s.state.errs, err)
+	} else {
+		err = ErrFailed
+	}
+	s.state.scope.hasFailed = true
+	s.state.cancel(err)
 	s.state.Broadcast()
+	s.Publish(Event{Kind: EventFailed, Err: err})
 	return nil
 }
 
+// Records `err` without recording a failure.
+//
+// Used for errors which are tolerated, so that they are still
+// reported by Err. Errors which were already recorded are ignored.
+//
+// Blocks until a write lock is acquired.
+func (s *Status) Report(err error) {
+	// Write lock
+	s.state.rw.Lock()
+	defer s.state.rw.Unlock()
+
+	if err != nil && !s.state.recorded(err) {
+		s.state.errs = append(s.state.errs, err)
+	}
+}
+
+// Whether `err` has already been recorded.
+// The caller must hold the lock.
+func (sc *scope) recorded(err error) bool {
+	for _, e := range sc.errs {
+		if errors.Is(e, err) {
+			return true
+		}
+	}
+	return false
+}
+
+// The errors which caused the recorded failure,
+// along with any reported errors.
+//
+// For an isolated copy, only the errors recorded by the
+// isolated copy and its bound copies are included.
+//
+// Blocks until a read lock is acquired.
+//
+// Returns:
+//  `nil` if no failure or error has been recorded.
+//  `ErrFailed` if a failure was recorded without a cause.
+//  the recorded error if there was only one.
+//  the recorded errors joined with `errors.Join` otherwise.
+func (s *Status) Err() error {
+	s.state.rw.RLock()
+	defer s.state.rw.RUnlock()
+
+	switch {
+	case len(s.state.errs) == 0 && !s.state.scope.hasFailed:
+		return nil
+	case len(s.state.errs) == 0:
+		return ErrFailed
+	case len(s.state.errs) == 1:
+		return s.state.errs[0]
+	}
+	return errors.Join(s.state.errs...)
+}
+
+// The status' context, shared with its bound copies.
+//
+// The context is cancelled when a failure is recorded;
+// `context.Cause` reports the first recorded error.
+func (s *Status) Context() context.Context {
+	return s.state.ctx
+}
+
 // Whether a failure has been recorded on this status object.
 //
 // Blocks until a read lock is acquired.
@@ -170,7 +357,7 @@ func (s *Status) Fail() error {
 func (s *Status) HasFailed() bool {
 	s.state.rw.RLock()
 	defer s.state.rw.RUnlock()
-	return s.state.hasFailed
+	return s.state.failed()
 }
 
 // Records a pause, undone by calling `Pause`.
@@ -186,10 +373,15 @@ func (s *Status) Pause() (bool, error) {
 	s.state.rw.Lock()
 	defer s.state.rw.Unlock()
 
-	if s.state.hasFailed {
+	if s.state.failed() {
 		return true, errors.New("A failure already occured.")
 	}
 	isPaused := s.state.isPaused
+	if !isPaused {
+		close(s.state.paused)
+		s.state.unpaused = make(chan struct{})
+		s.Publish(Event{Kind: EventPaused})
+	}
 	s.state.isPaused = true
 	s.state.Broadcast()
 	return isPaused, nil
@@ -202,6 +394,32 @@ func (s *Status) IsPaused() bool {
 	return s.state.isPaused
 }
 
+// A channel for waiting until the status is paused.
+//
+// Blocks until a read lock is acquired.
+//
+// Returns:
+//  a channel which is closed once the status is paused;
+//  it is already closed if the status is currently paused.
+func (s *Status) Paused() <-chan struct{} {
+	s.state.rw.RLock()
+	defer s.state.rw.RUnlock()
+	return s.state.paused
+}
+
+// A channel for waiting until the status isn't paused.
+//
+// Blocks until a read lock is acquired.
+//
+// Returns:
+//  a channel which is closed once the status isn't paused;
+//  it is already closed if the status isn't currently paused.
+func (s *Status) Unpaused() <-chan struct{} {
+	s.state.rw.RLock()
+	defer s.state.rw.RUnlock()
+	return s.state.unpaused
+}
+
 // Records a continuation, undoing a call to `Pause`.
 //
 // Blocks until a write lock is acquired.
@@ -215,10 +433,15 @@ func (s *Status) Cont() (bool, error) {
 	s.state.rw.Lock()
 	defer s.state.rw.Unlock()
 
-	if s.state.hasFailed {
+	if s.state.failed() {
 		return false, errors.New("A failure already occured.")
 	}
 	isPaused := s.state.isPaused
+	if isPaused {
+		s.state.paused = make(chan struct{})
+		close(s.state.unpaused)
+		s.Publish(Event{Kind: EventContinued})
+	}
 	s.state.isPaused = false
 	s.state.Broadcast()
 	return !isPaused, nil
@@ -233,3 +456,8 @@ func (s *Status) Add(delta int) {
 func (s *Status) Done() {
 	s.WaitGroup.Done()
 }
+
+// Wrapper function for `sync.WaitGroup.Wait()`
+func (s *Status) Wait() {
+	s.WaitGroup.Wait()
+}
@@ -1,9 +1,10 @@
 package status
 
 import (
+	"context"
 	"testing"
 	"time"
-	//"errors"
+	"errors"
 	//"fmt"
 	"strings"
 
@@ -618,3 +619,139 @@ func testPauseContFail(t *testing.T, status Interface) {
 		t.Error("Didn't instantly report failure when calling ReadyRLock()")
 	}
 }
+
+func TestFailWithErr(t *testing.T) {
+	t.Parallel()
+	status := New()
+	assert.Nil(t, status.Err(), "Reported an error before a failure")
+
+	first := errors.New("first")
+	second := errors.New("second")
+	assert.Nil(t, status.FailWith(first), "First failure reported an error")
+	assert.Equal(t, first, status.Err(), "Didn't report the first error")
+
+	// Later errors are still recorded, even through a bound copy.
+	assert.NotNil(t, status.BoundCopy().FailWith(second),
+		"Second failure didn't report an error")
+	assert.ErrorIs(t, status.Err(), first)
+	assert.ErrorIs(t, status.Err(), second)
+}
+
+func TestFailWithoutErr(t *testing.T) {
+	t.Parallel()
+	status := New()
+	status.Fail()
+	assert.Equal(t, ErrFailed, status.Err(), "Didn't report ErrFailed")
+}
+
+func TestFailCancelsContext(t *testing.T) {
+	t.Parallel()
+	status := New()
+	boundCopy := status.BoundCopy()
+	assert.Nil(t, boundCopy.Context().Err(), "Context cancelled before a failure")
+
+	failure := errors.New("failure")
+	status.FailWith(failure)
+	assert.NotNil(t, boundCopy.Context().Err(), "Context not cancelled by a failure")
+	assert.Equal(t, failure, context.Cause(boundCopy.Context()))
+
+	// Errors caused by the cancellation aren't recorded.
+	status.FailWith(boundCopy.Context().Err())
+	assert.Equal(t, failure, status.Err())
+}
+
+func TestUnpaused(t *testing.T) {
+	t.Parallel()
+	status := New()
+	boundCopy := status.BoundCopy()
+
+	select {
+	case <-boundCopy.Unpaused():
+		// Okay
+	default:
+		t.Error("Unpaused wasn't closed before pausing")
+	}
+
+	status.Pause()
+	unpaused := boundCopy.Unpaused()
+	select {
+	case <-unpaused:
+		t.Error("Unpaused was closed while paused")
+	default:
+		// Okay
+	}
+
+	status.Cont()
+	select {
+	case <-unpaused:
+		// Okay
+	case <-time.After(microDuration):
+		t.Error("Unpaused wasn't closed by Cont")
+	}
+}
+
+func TestIsolatedCopy(t *testing.T) {
+	t.Parallel()
+	status := New()
+	isolated := status.IsolatedCopy()
+
+	// Failures of the isolated copy don't propagate.
+	failure := errors.New("failure")
+	assert.Nil(t, isolated.FailWith(failure))
+	assert.True(t, isolated.HasFailed())
+	assert.NotNil(t, isolated.Context().Err())
+	assert.Equal(t, failure, isolated.Err())
+	assert.False(t, status.HasFailed(), "The isolated failure propagated")
+	assert.Nil(t, status.Context().Err(), "The isolated failure cancelled the context")
+	assert.Nil(t, status.Err())
+
+	// Pausing is still shared.
+	isolated = status.IsolatedCopy()
+	isolated.Pause()
+	assert.True(t, status.IsPaused())
+	status.Cont()
+
+	// Failures of the original do propagate.
+	status.Fail()
+	assert.True(t, isolated.HasFailed())
+	assert.NotNil(t, isolated.Context().Err())
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+	status := New()
+	tolerated := errors.New("tolerated")
+	status.Report(tolerated)
+	status.Report(tolerated)
+
+	assert.False(t, status.HasFailed(), "Reporting an error caused a failure")
+	assert.Equal(t, tolerated, status.Err())
+}
+
+func TestPaused(t *testing.T) {
+	t.Parallel()
+	status := New()
+	paused := status.BoundCopy().Paused()
+	select {
+	case <-paused:
+		t.Error("Paused was closed before pausing")
+	default:
+		// Okay
+	}
+
+	status.Pause()
+	select {
+	case <-paused:
+		// Okay
+	case <-time.After(microDuration):
+		t.Error("Paused wasn't closed by Pause")
+	}
+
+	status.Cont()
+	select {
+	case <-status.Paused():
+		t.Error("Paused was closed after continuing")
+	default:
+		// Okay
+	}
+}